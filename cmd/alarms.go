@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var alarmsObject string
+var alarmsName string
+var alarmsMaxAge string
+
+// alarmsCmd represents the alarms command.
+var alarmsCmd = &cobra.Command{
+	Use:   "alarms",
+	Short: "Checks triggered vCenter alarms of all objects or a singular, specified object",
+	Long: `Checks the alarms currently triggered (yellow or red) on all objects of the vCenter given
+by --machine, or on a singular object given by --object, based on vSphereDB's alarm history.
+
+Yellow alarms result in WARNING, red alarms in CRITICAL. Ages can be given in seconds or
+with one of the units s, m, h, d or w, e.g. --max-age 7d.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryAlarms()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(alarmsCmd)
+
+	alarmsCmd.Flags().StringVar(&alarmsObject, "object", "", "Object to check, all objects of the vCenter if empty")
+	alarmsCmd.Flags().StringVar(&alarmsName, "alarm", "", "Regular expression of alarm names to include")
+	alarmsCmd.Flags().StringVar(&alarmsMaxAge, "max-age", "", "Ignore alarms triggered longer ago than the given age")
+}
+
+// Query for triggered alarms of the given vCenter, exit with UNKNOWN on query errors.
+func queryAlarms() {
+	var (
+		err    error
+		maxAge time.Duration
+	)
+
+	aggregatedResult := result.Overall{}
+
+	alarmNameFilter, err := regexp.Compile(alarmsName)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	if alarmsMaxAge != "" {
+		maxAge, err = internal.ParseAge(alarmsMaxAge)
+		if err != nil {
+			check.ExitError(err)
+		}
+	}
+
+	objectFilter := alarmsObject
+	if objectFilter == "" {
+		objectFilter = "%"
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	// Only the latest transition per object and alarm reflects the current alarm status.
+	rows, err := dbConnection.Query(`SELECT o.object_name, ah.alarm_name, ah.status_to, ah.ts_event_ms
+		FROM alarm_history ah
+		INNER JOIN (
+			SELECT entity_uuid, alarm_name, MAX(ts_event_ms) AS ts_last
+			FROM alarm_history
+			GROUP BY entity_uuid, alarm_name
+		) latest
+		ON ah.entity_uuid = latest.entity_uuid
+		AND ah.alarm_name = latest.alarm_name
+		AND ah.ts_event_ms = latest.ts_last
+		INNER JOIN vcenter vc
+		ON ah.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON ah.entity_uuid = o.uuid
+		WHERE vc.name LIKE ?
+		AND o.object_name LIKE ?
+		AND ah.status_to IN ('yellow', 'red')
+		ORDER BY ah.ts_event_ms DESC`,
+		machine,
+		objectFilter)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for _, pr := range collectAlarms(rows, alarmNameFilter, maxAge) {
+		aggregatedResult.AddSubcheck(pr)
+	}
+
+	dbConnection.Close()
+
+	// Add a summary including perfdata on the number of triggered alarms.
+	pr := internal.StatePartialResult(fmt.Sprintf("%d triggered alarms", len(aggregatedResult.PartialResults)), check.OK)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "alarms",
+		Value: len(aggregatedResult.PartialResults),
+	})
+	aggregatedResult.AddSubcheck(pr)
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes one PartialResult per triggered alarm from the query results, skipping alarms not matching
+// --alarm or older than --max-age, exit with UNKNOWN on scan errors.
+func collectAlarms(rows *sql.Rows, alarmNameFilter *regexp.Regexp, maxAge time.Duration) []result.PartialResult {
+	var (
+		objectName string
+		alarmName  string
+		status     string
+		tsEventMs  int64
+		alarms     []result.PartialResult
+	)
+
+	for rows.Next() {
+		// Read row into variables.
+		err := rows.Scan(&objectName, &alarmName, &status, &tsEventMs)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		triggeredAt := time.UnixMilli(tsEventMs)
+
+		if !alarmNameFilter.MatchString(alarmName) || (maxAge != 0 && time.Since(triggeredAt) > maxAge) {
+			continue
+		}
+
+		alarms = append(alarms, internal.StatePartialResult(
+			fmt.Sprintf("Alarm '%s' on %s is %s since %s", alarmName, objectName, status, triggeredAt.Format(time.DateTime)),
+			internal.StateFromColor(status)))
+	}
+
+	return alarms
+}
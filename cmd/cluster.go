@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var clusterCPUWarning string
+var clusterCPUCritical string
+var clusterMemoryWarning string
+var clusterMemoryCritical string
+var clusterHostsWarning string
+var clusterHostsCritical string
+var clusterHeadroomState string
+var clusterCPUWarnThreshold *check.Threshold
+var clusterCPUCritThreshold *check.Threshold
+var clusterMemoryWarnThreshold *check.Threshold
+var clusterMemoryCritThreshold *check.Threshold
+var clusterHostsWarnThreshold *check.Threshold
+var clusterHostsCritThreshold *check.Threshold
+
+// clusterStats holds the aggregated data of all connected hosts of a compute cluster.
+type clusterStats struct {
+	effectiveCPUMHz      int64
+	effectiveMemoryMB    int64
+	numHosts             int64
+	connectedHosts       int64
+	overallCPUUsage      int64
+	overallMemoryUsageMB int64
+	largestHostCPUMHz    int64
+	largestHostMemoryMB  int64
+}
+
+// clusterCmd represents the cluster command.
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Checks aggregated CPU and memory usage of a compute cluster",
+	Long: `Checks the aggregated CPU and memory usage of all connected hosts of the compute cluster
+given by --machine against the cluster's effective resources, as well as the number of
+connected hosts.
+
+If the usage does not fit into the effective resources without the cluster's largest host
+(N+1 headroom), the state given by --headroom-state is returned.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryCluster()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+
+	clusterCmd.Flags().StringVarP(&clusterCPUWarning, "warning", "w", "80", "Warning threshold for CPU usage in percent as Integer")
+	clusterCmd.Flags().StringVarP(&clusterCPUCritical, "critical", "c", "90", "Critical threshold for CPU usage in percent as Integer")
+	clusterCmd.Flags().StringVar(&clusterMemoryWarning, "memory-warning", "80", "Warning threshold for memory usage in percent as Integer")
+	clusterCmd.Flags().StringVar(&clusterMemoryCritical, "memory-critical", "90", "Critical threshold for memory usage in percent as Integer")
+	clusterCmd.Flags().StringVar(&clusterHostsWarning, "hosts-warning", "0", "Warning threshold for connected hosts as Integer (\"less than X connected\")")
+	clusterCmd.Flags().StringVar(&clusterHostsCritical, "hosts-critical", "1", "Critical threshold for connected hosts as Integer (\"less than X connected\")")
+	clusterCmd.Flags().StringVar(&clusterHeadroomState, "headroom-state", "warning", "State if the usage exceeds the N+1 headroom")
+}
+
+// Query for aggregated resource usage of the given cluster, exit with UNKNOWN on query errors.
+func queryCluster() {
+	// Parse thresholds from given flags.
+	clusterCPUWarnThreshold, clusterCPUCritThreshold = internal.ParseThresholds(clusterCPUWarning, clusterCPUCritical)
+	clusterMemoryWarnThreshold, clusterMemoryCritThreshold = internal.ParseThresholds(clusterMemoryWarning, clusterMemoryCritical)
+	clusterHostsWarnThreshold, clusterHostsCritThreshold = internal.ParseThresholds(clusterHostsWarning+":", clusterHostsCritical+":") // `:` is needed because warning/critical are reversed.
+
+	stats := fetchClusterStats()
+
+	aggregatedResult := result.Overall{}
+
+	aggregatedResult.AddSubcheck(clusterUsageResult("CPU",
+		stats.overallCPUUsage,
+		stats.effectiveCPUMHz,
+		stats.largestHostCPUMHz,
+		"MHz",
+		clusterCPUWarnThreshold,
+		clusterCPUCritThreshold))
+	aggregatedResult.AddSubcheck(clusterUsageResult("Memory",
+		stats.overallMemoryUsageMB,
+		stats.effectiveMemoryMB,
+		stats.largestHostMemoryMB,
+		"MB",
+		clusterMemoryWarnThreshold,
+		clusterMemoryCritThreshold))
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("%d of %d hosts connected", stats.connectedHosts, stats.numHosts),
+		internal.EvaluateThresholds(float64(stats.connectedHosts), clusterHostsWarnThreshold, clusterHostsCritThreshold))
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "hosts_connected",
+		Value: stats.connectedHosts,
+		Warn:  clusterHostsWarnThreshold,
+		Crit:  clusterHostsCritThreshold,
+		Min:   0,
+		Max:   stats.numHosts,
+	})
+	aggregatedResult.AddSubcheck(pr)
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Fetches the aggregated data of all connected hosts of the given cluster, exit with UNKNOWN on query errors.
+func fetchClusterStats() clusterStats {
+	var stats clusterStats
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err := dbConnection.QueryRow(`SELECT cr.effective_cpu_mhz,
+		cr.effective_memory_size_mb,
+		cr.num_hosts,
+		COUNT(hs.uuid),
+		COALESCE(SUM(hqs.overall_cpu_usage), 0),
+		COALESCE(SUM(hqs.overall_memory_usage_mb), 0),
+		COALESCE(MAX(hs.hardware_cpu_mhz * hs.hardware_cpu_cores), 0),
+		COALESCE(MAX(hs.hardware_memory_size_mb), 0)
+		FROM compute_resource cr
+		INNER JOIN object o
+		ON cr.uuid = o.uuid
+		LEFT JOIN object ho
+		ON ho.parent_uuid = cr.uuid
+		AND ho.object_type = 'HostSystem'
+		LEFT JOIN host_system hs
+		ON ho.uuid = hs.uuid
+		AND hs.runtime_connection_state = 'connected'
+		LEFT JOIN host_quick_stats hqs
+		ON hs.uuid = hqs.uuid
+		WHERE o.object_name LIKE ?
+		AND o.object_type = 'ClusterComputeResource'
+		GROUP BY cr.uuid, cr.effective_cpu_mhz, cr.effective_memory_size_mb, cr.num_hosts`,
+		machine).Scan(&stats.effectiveCPUMHz, &stats.effectiveMemoryMB, &stats.numHosts, &stats.connectedHosts,
+		&stats.overallCPUUsage, &stats.overallMemoryUsageMB, &stats.largestHostCPUMHz, &stats.largestHostMemoryMB)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+
+	return stats
+}
+
+// Computes the PartialResult and Perfdata for an aggregated cluster resource,
+// including whether the usage fits into the N+1 headroom.
+func clusterUsageResult(resource string, usage, effective, largestHost int64, uom string,
+	warnThreshold, critThreshold *check.Threshold) result.PartialResult {
+	// calculate percentage usage for check result decision.
+	usagePercent := int64(0)
+	if effective != 0 {
+		usagePercent = usage * 100 / effective
+	}
+
+	state := internal.EvaluateThresholds(float64(usagePercent), warnThreshold, critThreshold)
+	output := fmt.Sprintf("%s usage is %d%s of %d%s effective (%d%%)", resource, usage, uom, effective, uom, usagePercent)
+
+	// N+1 headroom, i.e. the usage has to fit into the effective resources without the largest host.
+	if headroom := effective - largestHost; usage > headroom {
+		state = result.WorstState(state, internal.ParseState(clusterHeadroomState))
+		output += fmt.Sprintf(", exceeding N+1 headroom of %d%s", headroom, uom)
+	}
+
+	pr := internal.StatePartialResult(output, state)
+
+	// Report memory in Bytes.
+	perfdataUom := ""
+	perfdataScale := int64(1)
+
+	if uom == "MB" {
+		perfdataUom = "B"
+		perfdataScale = 1024 * 1024
+	}
+
+	label := strings.ToLower(resource)
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: label + "_usage",
+		Value: usage * perfdataScale,
+		Uom:   perfdataUom,
+		Min:   0,
+		Max:   effective * perfdataScale,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: label + "_usage_percent",
+		Value: usagePercent,
+		Uom:   "%",
+		Warn:  warnThreshold,
+		Crit:  critThreshold,
+	})
+
+	return pr
+}
@@ -0,0 +1,166 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var clusterFailoverWarning string
+var clusterFailoverCritical string
+var clusterFailoverHosts int
+var clusterFailoverWarnThreshold *check.Threshold
+var clusterFailoverCritThreshold *check.Threshold
+
+// clusterFailoverCmd represents the cluster-failover command.
+var clusterFailoverCmd = &cobra.Command{
+	Use:   "cluster-failover",
+	Short: "Checks whether a compute cluster could survive the failure of its largest hosts",
+	Long: `Checks whether the remaining connected hosts of the compute cluster given by --machine
+could absorb the CPU and memory usage of the whole cluster if its N largest hosts failed.
+
+The headroom is the capacity left on the remaining hosts in percent of their total capacity,
+a negative headroom means the load could not be absorbed.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryClusterFailover()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(clusterFailoverCmd)
+
+	clusterFailoverCmd.Flags().StringVarP(&clusterFailoverWarning, "warning", "w", "10", "Warning threshold for headroom in percent as Integer (\"less than X left\")")
+	clusterFailoverCmd.Flags().StringVarP(&clusterFailoverCritical, "critical", "c", "0", "Critical threshold for headroom in percent as Integer (\"less than X left\")")
+	clusterFailoverCmd.Flags().IntVarP(&clusterFailoverHosts, "failures", "n", 1, "Number of largest hosts to assume as failed")
+}
+
+// Query for failover capacity of the given cluster, exit with UNKNOWN on query errors.
+func queryClusterFailover() {
+	var (
+		err             error
+		connectionState string
+		numHosts        int
+		hostCPUMHz      int64
+		hostMemoryMB    int64
+		cpuUsage        int64
+		memoryUsageMB   int64
+		hostCPUs        []int64
+		hostMemories    []int64
+		totalCPUUsage   int64
+		totalMemoryUsed int64
+	)
+
+	aggregatedResult := result.Overall{}
+
+	if clusterFailoverHosts < 0 {
+		check.ExitError(fmt.Errorf("invalid number of failures: %d", clusterFailoverHosts))
+	}
+
+	// Parse thresholds from given flags.
+	clusterFailoverWarnThreshold, clusterFailoverCritThreshold = internal.ParseThresholds(clusterFailoverWarning+":", clusterFailoverCritical+":") // `:` is needed because warning/critical are reversed.
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT hs.runtime_connection_state,
+		hs.hardware_cpu_mhz * hs.hardware_cpu_cores,
+		hs.hardware_memory_size_mb,
+		COALESCE(hqs.overall_cpu_usage, 0),
+		COALESCE(hqs.overall_memory_usage_mb, 0)
+		FROM host_system hs
+		INNER JOIN object ho
+		ON hs.uuid = ho.uuid
+		INNER JOIN object o
+		ON ho.parent_uuid = o.uuid
+		LEFT JOIN host_quick_stats hqs
+		ON hs.uuid = hqs.uuid
+		WHERE o.object_name LIKE ?
+		AND o.object_type = 'ClusterComputeResource'`,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&connectionState, &hostCPUMHz, &hostMemoryMB, &cpuUsage, &memoryUsageMB)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		numHosts++
+
+		// Only connected hosts contribute to the failover capacity.
+		if connectionState != "connected" {
+			continue
+		}
+
+		hostCPUs = append(hostCPUs, hostCPUMHz)
+		hostMemories = append(hostMemories, hostMemoryMB)
+		totalCPUUsage += cpuUsage
+		totalMemoryUsed += memoryUsageMB
+	}
+
+	dbConnection.Close()
+
+	if numHosts == 0 {
+		check.ExitRaw(check.Unknown, "No hosts found for cluster "+machine)
+	}
+
+	if len(hostCPUs) <= clusterFailoverHosts {
+		check.ExitRaw(check.Critical,
+			fmt.Sprintf("Cluster %s has %d connected hosts, cannot survive the failure of %d hosts", machine, len(hostCPUs), clusterFailoverHosts))
+	}
+
+	aggregatedResult.AddSubcheck(clusterFailoverResult("CPU", hostCPUs, totalCPUUsage, "MHz"))
+	aggregatedResult.AddSubcheck(clusterFailoverResult("Memory", hostMemories, totalMemoryUsed, "MB"))
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult and Perfdata for the failover headroom of a cluster resource.
+func clusterFailoverResult(resource string, hostCapacities []int64, usage int64, uom string) result.PartialResult {
+	// Remove the largest hosts from the cluster's capacity.
+	sort.Slice(hostCapacities, func(i, j int) bool { return hostCapacities[i] > hostCapacities[j] })
+
+	remaining := int64(0)
+	for _, capacity := range hostCapacities[clusterFailoverHosts:] {
+		remaining += capacity
+	}
+
+	// calculate percentage headroom for check result decision.
+	headroomPercent := int64(-100)
+	if remaining != 0 {
+		headroomPercent = (remaining - usage) * 100 / remaining
+	}
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("%s usage of %d%s on %d remaining hosts with %d%s capacity leaves %d%% headroom",
+			resource,
+			usage,
+			uom,
+			len(hostCapacities)-clusterFailoverHosts,
+			remaining,
+			uom,
+			headroomPercent),
+		internal.EvaluateThresholds(float64(headroomPercent), clusterFailoverWarnThreshold, clusterFailoverCritThreshold))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: strings.ToLower(resource) + "_headroom_percent",
+		Value: headroomPercent,
+		Uom:   "%",
+		Warn:  clusterFailoverWarnThreshold,
+		Crit:  clusterFailoverCritThreshold,
+	})
+
+	return pr
+}
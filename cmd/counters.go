@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+)
+
+// vSphereDB keeps the last five 5-minute rollups per performance counter.
+const (
+	counterInterval   = 5 * time.Minute
+	counterMaxSamples = 5
+)
+
+// Validates the --window and --aggregate flags and returns the window,
+// exit with UNKNOWN on invalid flags.
+func parseCounterWindow(window, aggregate string) time.Duration {
+	if aggregate != "avg" && aggregate != "max" {
+		check.ExitError(fmt.Errorf("invalid aggregation: %s", aggregate))
+	}
+
+	duration, err := internal.ParseAge(window)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	if duration < counterInterval || duration > counterMaxSamples*counterInterval {
+		check.ExitError(fmt.Errorf("window has to be between %.0fm and %.0fm",
+			counterInterval.Minutes(),
+			(counterMaxSamples * counterInterval).Minutes()))
+	}
+
+	return duration
+}
+
+// Collects the samples of a counter within the window, newest first. The timestamp of each sample is
+// derived from the timestamp of the latest rollup, samples older than the window are dropped.
+func counterSamples(values []sql.NullInt64, tsLast int64, window time.Duration) []float64 {
+	var collected []float64
+
+	since := time.Now().Add(-window).UnixMilli()
+
+	for i, value := range values {
+		if value.Valid && tsLast-int64(i)*counterInterval.Milliseconds() >= since {
+			collected = append(collected, float64(value.Int64))
+		}
+	}
+
+	return collected
+}
+
+// Reports whether the latest rollup of a counter, given by its timestamp, is current,
+// so stale values are not evaluated if vSphereDB stopped collecting.
+func counterIsCurrent(tsLast int64) bool {
+	return tsLast >= time.Now().Add(-2*counterInterval).UnixMilli()
+}
+
+// Queries the 5-minute rollups of a host's performance counter given by group and name, and aggregates
+// the samples within the window, exit with UNKNOWN on query errors or if no samples are within the window.
+func queryHostCounter(dbConnection *sql.DB, group, name string, window time.Duration, aggregate string) float64 {
+	var (
+		tsLast int64
+		values = make([]sql.NullInt64, counterMaxSamples)
+	)
+
+	err := dbConnection.QueryRow(`SELECT c.ts_last,
+		c.value_last,
+		c.value_minus1,
+		c.value_minus2,
+		c.value_minus3,
+		c.value_minus4
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN host_system hs
+		ON c.object_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		AND pc.group_name = ?
+		AND pc.name = ?
+		AND pc.rollup_type = 'average'
+		AND c.instance = ''`,
+		machine,
+		group,
+		name).Scan(&tsLast, &values[0], &values[1], &values[2], &values[3], &values[4])
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	samples := counterSamples(values, tsLast, window)
+
+	if len(samples) == 0 {
+		check.ExitError(fmt.Errorf("no samples of counter %s.%s within the last %s", group, name, window))
+	}
+
+	return aggregateSamples(samples, aggregate)
+}
+
+// Aggregates samples by the method given by --aggregate.
+func aggregateSamples(samples []float64, aggregate string) float64 {
+	if aggregate == "max" {
+		return slices.Max(samples)
+	}
+
+	sum := 0.0
+	for _, sample := range samples {
+		sum += sample
+	}
+
+	return sum / float64(len(samples))
+}
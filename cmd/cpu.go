@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
@@ -11,11 +14,18 @@ var cpuWarning string
 var cpuCritical string
 var cpuWarnThreshold *check.Threshold
 var cpuCritThreshold *check.Threshold
+var cpuWindow string
+var cpuAggregate string
 
 // cpuCmd represents the cpu command.
 var cpuCmd = &cobra.Command{
 	Use:   "cpu",
 	Short: "Checks CPU usage",
+	Long: `Checks the CPU usage of the ESXi host given by --machine.
+
+By default, the current quick stats are evaluated. With --window, the 5-minute rollups of
+the CPU usage counter within the window (5m to 25m) are aggregated by --aggregate
+(avg or max) instead, to avoid alerts on short spikes.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryCPU()
 	},
@@ -25,6 +35,8 @@ func init() {
 	rootCmd.AddCommand(cpuCmd)
 	cpuCmd.Flags().StringVarP(&cpuWarning, "warning", "w", "80", "Warning threshold in percent as Integer")
 	cpuCmd.Flags().StringVarP(&cpuCritical, "critical", "c", "90", "Critical threshold in percent as Integer")
+	cpuCmd.Flags().StringVar(&cpuWindow, "window", "", "Window of performance counter rollups to evaluate instead of quick stats, e.g. 15m")
+	cpuCmd.Flags().StringVar(&cpuAggregate, "aggregate", "avg", "Aggregation of performance counter rollups (avg, max)")
 }
 
 // Query for CPU usage of the given machine, exit with UNKNOWN on query errors.
@@ -34,18 +46,17 @@ func queryCPU() {
 		overallCPUUsage  int64
 		hardwareCPUMHz   int64
 		hardwareCPUCores int64
+		window           time.Duration
+		windowOutput     string
 	)
 
-	// Parse thresholds from given flags.
-	cpuWarnThreshold, err = check.ParseThreshold(cpuWarning)
-	if err != nil {
-		check.ExitError(err)
+	// Validate the window before querying.
+	if cpuWindow != "" {
+		window = parseCounterWindow(cpuWindow, cpuAggregate)
 	}
 
-	cpuCritThreshold, err = check.ParseThreshold(cpuCritical)
-	if err != nil {
-		check.ExitError(err)
-	}
+	// Parse thresholds from given flags.
+	cpuWarnThreshold, cpuCritThreshold = internal.ParseThresholds(cpuWarning, cpuCritical)
 
 	dbConnection := internal.DBConnection(host, port, username, password, database)
 
@@ -64,6 +75,14 @@ func queryCPU() {
 	// calculate percentage usage for check result decision.
 	cpuUsagePercent := overallCPUUsage * 100 / (hardwareCPUCores * hardwareCPUMHz)
 
+	if cpuWindow != "" {
+		// cpu.usage is reported in hundredths of a percent.
+		usage := queryHostCounter(dbConnection, "cpu", "usage", window, cpuAggregate) / 100
+		windowOutput = fmt.Sprintf(" (%s over %s)", cpuAggregate, cpuWindow)
+		cpuUsagePercent = int64(usage)
+		overallCPUUsage = int64(usage * float64(hardwareCPUCores*hardwareCPUMHz) / 100)
+	}
+
 	// Add Perfdata.
 	// total usage.
 	pl.Add(&perfdata.Perfdata{
@@ -89,21 +108,11 @@ func queryCPU() {
 		Value: hardwareCPUCores,
 	})
 
-	// Decide on check result state.
-	statusCode := check.OK
-
-	if cpuWarnThreshold.DoesViolate(float64(cpuUsagePercent)) {
-		statusCode = check.Warning
-	}
-
-	if cpuCritThreshold.DoesViolate(float64(cpuUsagePercent)) {
-		statusCode = check.Critical
-	}
-
 	dbConnection.Close()
-	check.Exitf(statusCode,
-		"Total CPU usage is %dGHz (%d%%) | %s",
+	check.Exitf(internal.EvaluateThresholds(float64(cpuUsagePercent), cpuWarnThreshold, cpuCritThreshold),
+		"Total CPU usage is %dGHz (%d%%)%s | %s",
 		overallCPUUsage/1024,
 		cpuUsagePercent,
+		windowOutput,
 		pl.String())
 }
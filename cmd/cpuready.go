@@ -0,0 +1,234 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var cpuReadyWarning string
+var cpuReadyCritical string
+var cpuReadyCostopWarning string
+var cpuReadyCostopCritical string
+var cpuReadyType string
+var cpuReadyTop int
+var cpuReadyWarnThreshold *check.Threshold
+var cpuReadyCritThreshold *check.Threshold
+var cpuReadyCostopWarnThreshold *check.Threshold
+var cpuReadyCostopCritThreshold *check.Threshold
+
+// vmCPUReady holds the CPU ready and co-stop time of a single VM.
+type vmCPUReady struct {
+	name          string
+	numCPU        int64
+	readyPercent  float64
+	costopPercent float64
+	state         int
+}
+
+// cpuReadyCmd represents the cpu-ready command.
+var cpuReadyCmd = &cobra.Command{
+	Use:   "cpu-ready",
+	Short: "Checks CPU ready and co-stop time of a VM or all VMs of a host",
+	Long: `Checks CPU ready and co-stop time of the virtual machine given by --machine, or of all
+virtual machines running on the ESXi host given by --machine with --type host.
+
+The summation counters of the latest 5-minute rollup are converted into percent of the
+sampling interval per virtual CPU, rollups older than two intervals are ignored. With
+--type host, the top offenders are listed.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryCPUReady()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(cpuReadyCmd)
+
+	cpuReadyCmd.Flags().StringVarP(&cpuReadyWarning, "warning", "w", "5", "Warning threshold for CPU ready in percent")
+	cpuReadyCmd.Flags().StringVarP(&cpuReadyCritical, "critical", "c", "10", "Critical threshold for CPU ready in percent")
+	cpuReadyCmd.Flags().StringVar(&cpuReadyCostopWarning, "costop-warning", "3", "Warning threshold for co-stop in percent")
+	cpuReadyCmd.Flags().StringVar(&cpuReadyCostopCritical, "costop-critical", "5", "Critical threshold for co-stop in percent")
+	cpuReadyCmd.Flags().StringVarP(&cpuReadyType, "type", "t", "vm", "Type of the machine to check (vm, host)")
+	cpuReadyCmd.Flags().IntVar(&cpuReadyTop, "top", 5, "Number of top offenders to list with --type host")
+}
+
+// Query for CPU ready and co-stop time of the given VM or host, exit with UNKNOWN on query errors.
+func queryCPUReady() {
+	var query string
+
+	aggregatedResult := result.Overall{}
+
+	// Parse thresholds from given flags.
+	cpuReadyWarnThreshold, cpuReadyCritThreshold = internal.ParseThresholds(cpuReadyWarning, cpuReadyCritical)
+	cpuReadyCostopWarnThreshold, cpuReadyCostopCritThreshold = internal.ParseThresholds(cpuReadyCostopWarning, cpuReadyCostopCritical)
+
+	switch cpuReadyType {
+	case "vm":
+		query = `SELECT o.object_name, vm.hardware_numcpu, pc.name, c.ts_last, COALESCE(c.value_last, 0)
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN virtual_machine vm
+		ON c.object_uuid = vm.uuid
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		AND pc.group_name = 'cpu'
+		AND pc.name IN ('ready', 'costop')
+		AND pc.rollup_type = 'summation'
+		AND c.instance = ''`
+	case "host":
+		query = `SELECT o.object_name, vm.hardware_numcpu, pc.name, c.ts_last, COALESCE(c.value_last, 0)
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN virtual_machine vm
+		ON c.object_uuid = vm.uuid
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		INNER JOIN host_system hs
+		ON vm.runtime_host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		AND pc.group_name = 'cpu'
+		AND pc.name IN ('ready', 'costop')
+		AND pc.rollup_type = 'summation'
+		AND c.instance = ''`
+	default:
+		check.ExitError(fmt.Errorf("invalid type: %s", cpuReadyType))
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(query, machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	ranked := collectCPUReady(rows)
+
+	dbConnection.Close()
+
+	if len(ranked) == 0 {
+		check.ExitRaw(check.Unknown, "No current CPU ready counters found for "+cpuReadyType+" "+machine)
+	}
+
+	for _, vm := range rankCPUReady(ranked) {
+		aggregatedResult.AddSubcheck(processCPUReady(vm))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Reads the CPU ready and co-stop counters per VM from the query results, skipping stale rollups,
+// exit with UNKNOWN on scan errors.
+func collectCPUReady(rows *sql.Rows) []*vmCPUReady {
+	var (
+		vmName      string
+		numCPU      int64
+		counterName string
+		tsLast      int64
+		value       int64
+		vms         = map[string]*vmCPUReady{}
+		collected   []*vmCPUReady
+	)
+
+	for rows.Next() {
+		// Read row into variables.
+		err := rows.Scan(&vmName, &numCPU, &counterName, &tsLast, &value)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		if !counterIsCurrent(tsLast) {
+			continue
+		}
+
+		if _, ok := vms[vmName]; !ok {
+			vms[vmName] = &vmCPUReady{name: vmName, numCPU: max(numCPU, 1)}
+			collected = append(collected, vms[vmName])
+		}
+
+		// Summation of milliseconds within the interval, in percent per virtual CPU.
+		percent := float64(value) * 100 / float64(counterInterval.Milliseconds()) / float64(vms[vmName].numCPU)
+
+		if counterName == "ready" {
+			vms[vmName].readyPercent = percent
+		} else {
+			vms[vmName].costopPercent = percent
+		}
+	}
+
+	return collected
+}
+
+// Decides on the state of each VM and ranks them, limited to the top offenders with --type host.
+func rankCPUReady(ranked []*vmCPUReady) []*vmCPUReady {
+	for _, vm := range ranked {
+		vm.state = result.WorstState(
+			internal.EvaluateThresholds(vm.readyPercent, cpuReadyWarnThreshold, cpuReadyCritThreshold),
+			internal.EvaluateThresholds(vm.costopPercent, cpuReadyCostopWarnThreshold, cpuReadyCostopCritThreshold))
+	}
+
+	// Rank by state first, so the worst VMs are always listed, then by CPU ready.
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].state != ranked[j].state {
+			return cpuReadyStateRank(ranked[i].state) > cpuReadyStateRank(ranked[j].state)
+		}
+
+		return ranked[i].readyPercent > ranked[j].readyPercent
+	})
+
+	if len(ranked) > cpuReadyTop && cpuReadyType == "host" {
+		ranked = ranked[:max(cpuReadyTop, 1)]
+	}
+
+	return ranked
+}
+
+// Computes the PartialResult and Perfdata for a single VM.
+func processCPUReady(vm *vmCPUReady) result.PartialResult {
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("VM %s: CPU ready %.2f%%, co-stop %.2f%%", vm.name, vm.readyPercent, vm.costopPercent),
+		vm.state)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: vm.name + "_ready",
+		Value: vm.readyPercent,
+		Uom:   "%",
+		Warn:  cpuReadyWarnThreshold,
+		Crit:  cpuReadyCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: vm.name + "_costop",
+		Value: vm.costopPercent,
+		Uom:   "%",
+		Warn:  cpuReadyCostopWarnThreshold,
+		Crit:  cpuReadyCostopCritThreshold,
+	})
+
+	return pr
+}
+
+// Ranks check result states by severity, following result.WorstState.
+func cpuReadyStateRank(state int) int {
+	switch state {
+	case check.Critical:
+		return 3
+	case check.Unknown:
+		return 2
+	case check.Warning:
+		return 1
+	}
+
+	return 0
+}
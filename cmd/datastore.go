@@ -1,10 +1,15 @@
 package cmd
 
 import (
+	"database/sql"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/convert"
 	"github.com/NETWAYS/go-check/perfdata"
 	"github.com/NETWAYS/go-check/result"
 	"github.com/spf13/cobra"
@@ -12,14 +17,57 @@ import (
 
 var datastoreWarning string
 var datastoreCritical string
-var datastoreWarnThreshold *check.Threshold
-var datastoreCritThreshold *check.Threshold
 var datastore string
+var datastoreFreeWarning string
+var datastoreFreeCritical string
+var datastoreProvisionedWarning string
+var datastoreProvisionedCritical string
+var datastoreInclude string
+var datastoreExclude string
+var datastoreTypes []string
+var datastoreSkipEmpty bool
+
+// datastoreThresholds holds the thresholds on used, free and provisioned space of a datastore,
+// the thresholds on free and provisioned space are optional.
+type datastoreThresholds struct {
+	warn            *check.Threshold
+	crit            *check.Threshold
+	freeWarn        *check.Threshold
+	freeCrit        *check.Threshold
+	provisionedWarn *check.Threshold
+	provisionedCrit *check.Threshold
+}
+
+// datastoreUsage holds the queried space usage of a single datastore.
+type datastoreUsage struct {
+	name        string
+	uuid        []byte
+	capacity    int64
+	freeSpace   int64
+	uncommitted int64
+}
 
 // datastoreCmd represents the datastore command.
 var datastoreCmd = &cobra.Command{
 	Use:   "datastore",
 	Short: "Checks all datastores or a singular, specified datastore",
+	Long: `Checks the used storage space of all datastores of the vCenter given by --machine,
+or of a singular datastore given by --datastore.
+
+Thresholds are given on the used space in percent by default. If --warning-free or
+--critical-free are given, thresholds are evaluated on the free space instead, which can
+be given with a unit, e.g. --warning-free 500GB.
+
+Optionally, the provisioned space including the space not yet committed by thin-provisioned
+disks, (capacity - free + uncommitted) / capacity, is checked against --provisioned-warning
+and --provisioned-critical in percent.
+
+When checking all datastores, datastores can be filtered by name (--include, --exclude),
+by type (--type, e.g. VMFS, NFS, vsan or VVOL) and by capacity (--skip-empty).
+
+With --forecast, a linear trend of the used space over --forecast-window is computed from
+vSphereDB's usage history, and the projected days until the datastore is full are checked
+against --days-warning and --days-critical.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryDatastore()
 	},
@@ -38,29 +86,32 @@ func init() {
 	datastoreCmd.Flags().StringVarP(&datastoreWarning, "warning", "w", "80", "Warning threshold in percent as Integer")
 	datastoreCmd.Flags().StringVarP(&datastoreCritical, "critical", "c", "90", "Critical threshold in percent as Integer")
 	datastoreCmd.Flags().StringVarP(&datastore, "datastore", "s", "", "Datastore to check")
+	datastoreCmd.Flags().StringVar(&datastoreFreeWarning, "warning-free", "", "Warning threshold for free space in bytes (\"less than X free\")")
+	datastoreCmd.Flags().StringVar(&datastoreFreeCritical, "critical-free", "", "Critical threshold for free space in bytes (\"less than X free\")")
+	datastoreCmd.Flags().StringVar(&datastoreProvisionedWarning, "provisioned-warning", "", "Warning threshold for provisioned space in percent as Integer")
+	datastoreCmd.Flags().StringVar(&datastoreProvisionedCritical, "provisioned-critical", "", "Critical threshold for provisioned space in percent as Integer")
+	datastoreCmd.Flags().StringVar(&datastoreInclude, "include", "", "Regular expression of datastores to include when checking all datastores")
+	datastoreCmd.Flags().StringVar(&datastoreExclude, "exclude", "", "Regular expression of datastores to exclude when checking all datastores")
+	datastoreCmd.Flags().StringSliceVar(&datastoreTypes, "type", nil, "Comma separated list of datastore types to include when checking all datastores")
+	datastoreCmd.Flags().BoolVar(&datastoreSkipEmpty, "skip-empty", false, "Skip datastores with zero capacity when checking all datastores")
+	datastoreCmd.Flags().BoolVar(&datastoreForecast, "forecast", false, "Forecast the days until the datastore is full")
+	datastoreCmd.Flags().StringVar(&datastoreForecastWindow, "forecast-window", "7d", "Window of usage history the forecast is based on")
+	datastoreCmd.Flags().StringVar(&datastoreDaysWarning, "days-warning", "30", "Warning threshold for days until full as Integer (\"less than X days\")")
+	datastoreCmd.Flags().StringVar(&datastoreDaysCritical, "days-critical", "7", "Critical threshold for days until full as Integer (\"less than X days\")")
 }
 
 func queryDatastore() {
 	var (
-		err       error
-		capacity  int64
-		freeSpace int64
+		err error
+		ds  datastoreUsage
 	)
 
 	// Parse thresholds from given flags.
-	datastoreWarnThreshold, err = check.ParseThreshold(datastoreWarning)
-	if err != nil {
-		check.ExitError(err)
-	}
-
-	datastoreCritThreshold, err = check.ParseThreshold(datastoreCritical)
-	if err != nil {
-		check.ExitError(err)
-	}
+	thresholds := parseDatastoreFlags()
 
 	dbConnection := internal.DBConnection(host, port, username, password, database)
 
-	err = dbConnection.QueryRow(`SELECT ds.capacity, ds.free_space 
+	err = dbConnection.QueryRow(`SELECT o.object_name, ds.uuid, ds.capacity, ds.free_space, COALESCE(ds.uncommitted, 0) 
     	FROM datastore ds 
     	INNER JOIN vcenter vc 
     	ON ds.vcenter_uuid = vc.instance_uuid 
@@ -69,39 +120,45 @@ func queryDatastore() {
 		WHERE o.object_name LIKE ?
 		AND vc.name LIKE ?`,
 		datastore,
-		machine).Scan(&capacity, &freeSpace)
+		machine).Scan(&ds.name, &ds.uuid, &ds.capacity, &ds.freeSpace, &ds.uncommitted)
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	perfData, statusCode := processQueryResults(datastore, capacity, freeSpace)
-	pl.Add(&perfData)
+	perfData, statusCode := processQueryResults(ds.name, ds.capacity, ds.freeSpace, ds.uncommitted, thresholds)
+	pl = append(pl, perfData...)
+
+	output := fmt.Sprintf("Used storage space for datastore %s: %d%% (%s free)",
+		ds.name,
+		datastoreUsagePercent(ds.capacity, ds.freeSpace),
+		convert.BytesIEC(ds.freeSpace).HumanReadable())
+
+	if datastoreForecast {
+		forecastOutput, forecastPerfdata, forecastStatusCode := processForecast(dbConnection, ds.name, ds.uuid)
+		output += ", " + forecastOutput
+		statusCode = result.WorstState(statusCode, forecastStatusCode)
+
+		if forecastPerfdata != nil {
+			pl.Add(forecastPerfdata)
+		}
+	}
 
 	dbConnection.Close()
-	check.Exitf(statusCode,
-		"Used storage space for datastore %s: %d%% | %s",
-		datastore,
-		perfData.Value, // this is the used capacity in %
-		pl.String())
+	check.Exitf(statusCode, "%s | %s", output, pl.String())
 }
 
 func queryDatastores() {
-	var (
-		err           error
-		datastoreName string
-		capacity      int64
-		freeSpace     int64
-	)
-
 	aggregatedResult := result.Overall{}
 
 	// Parse thresholds from given flags.
-	datastoreWarnThreshold, err = check.ParseThreshold(datastoreWarning)
+	thresholds := parseDatastoreFlags()
+
+	include, err := regexp.Compile(datastoreInclude)
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	datastoreCritThreshold, err = check.ParseThreshold(datastoreCritical)
+	exclude, err := regexp.Compile(datastoreExclude)
 	if err != nil {
 		check.ExitError(err)
 	}
@@ -109,7 +166,7 @@ func queryDatastores() {
 	// Collect query results.
 	dbConnection := internal.DBConnection(host, port, username, password, database)
 
-	rows, err := dbConnection.Query(`SELECT o.object_name, ds.capacity, ds.free_space 
+	rows, err := dbConnection.Query(`SELECT o.object_name, ds.uuid, ds.type, ds.capacity, ds.free_space, COALESCE(ds.uncommitted, 0) 
     	FROM datastore ds 
     	INNER JOIN vcenter vc 
     	ON ds.vcenter_uuid = vc.instance_uuid 
@@ -121,67 +178,254 @@ func queryDatastores() {
 		check.ExitError(err)
 	}
 
-	defer rows.Close()
+	datastores := collectDatastores(rows, include, exclude)
+
+	// Close rows before the forecast runs further queries on the same connection.
+	rows.Close()
 
 	// Process query results.
+	for _, ds := range datastores {
+		// Calculate results and add PartialResult to Overall result.
+		pr := datastorePartialResult(ds.name, ds.capacity, ds.freeSpace, ds.uncommitted, thresholds)
+
+		if datastoreForecast {
+			addDatastoreForecast(dbConnection, &pr, ds)
+		}
+
+		aggregatedResult.AddSubcheck(pr)
+	}
+
+	dbConnection.Close()
+
+	if len(aggregatedResult.PartialResults) == 0 {
+		check.ExitRaw(check.Unknown, "No datastores found for vCenter "+machine)
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Reads the datastores from the query results, skipping datastores not matching the filters given
+// by --include, --exclude, --type and --skip-empty, exit with UNKNOWN on scan errors.
+func collectDatastores(rows *sql.Rows, include, exclude *regexp.Regexp) []datastoreUsage {
+	var (
+		datastoreType string
+		datastores    []datastoreUsage
+	)
+
 	for rows.Next() {
+		var ds datastoreUsage
+
 		// Read row into variables.
-		err = rows.Scan(&datastoreName, &capacity, &freeSpace)
+		err := rows.Scan(&ds.name, &ds.uuid, &datastoreType, &ds.capacity, &ds.freeSpace, &ds.uncommitted)
 		if err != nil {
 			check.ExitError(err)
 		}
 
-		// Calculate results and add perf data to list.
-		perfData, state := processQueryResults(datastoreName, capacity, freeSpace)
+		// Apply filters.
+		if !include.MatchString(ds.name) || (datastoreExclude != "" && exclude.MatchString(ds.name)) {
+			continue
+		}
+
+		if len(datastoreTypes) > 0 && !slices.ContainsFunc(datastoreTypes, func(t string) bool { return strings.EqualFold(t, datastoreType) }) {
+			continue
+		}
+
+		if datastoreSkipEmpty && ds.capacity == 0 {
+			continue
+		}
+
+		datastores = append(datastores, ds)
+	}
+
+	return datastores
+}
+
+// Adds the forecast of days until full to the PartialResult of a datastore.
+func addDatastoreForecast(dbConnection *sql.DB, pr *result.PartialResult, ds datastoreUsage) {
+	forecastOutput, forecastPerfdata, forecastStatusCode := processForecast(dbConnection, ds.name, ds.uuid)
+	pr.Output += ", " + forecastOutput
 
-		// Create PartialResult and add to Overall result.
-		pr := result.PartialResult{
-			Output: fmt.Sprintf("Used storage for datastore %s: %d%%", datastoreName, perfData.Value),
+	err := pr.SetState(result.WorstState(pr.GetStatus(), forecastStatusCode))
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	if forecastPerfdata != nil {
+		pr.Perfdata.Add(forecastPerfdata)
+	}
+}
+
+// Parses the thresholds in percent and the optional thresholds on free and provisioned space,
+// exit with UNKNOWN on parsing errors.
+func parseDatastoreThresholds(warning, critical, freeWarning, freeCritical, provisionedWarning, provisionedCritical string) datastoreThresholds {
+	var (
+		err        error
+		thresholds datastoreThresholds
+	)
+
+	thresholds.warn, thresholds.crit = internal.ParseThresholds(warning, critical)
+
+	if freeWarning != "" {
+		thresholds.freeWarn = internal.ParseFreeBytesThreshold(freeWarning)
+	}
+
+	if freeCritical != "" {
+		thresholds.freeCrit = internal.ParseFreeBytesThreshold(freeCritical)
+	}
+
+	if provisionedWarning != "" {
+		thresholds.provisionedWarn, err = check.ParseThreshold(provisionedWarning)
+		if err != nil {
+			check.ExitError(err)
 		}
+	}
 
-		err = pr.SetState(state)
+	if provisionedCritical != "" {
+		thresholds.provisionedCrit, err = check.ParseThreshold(provisionedCritical)
 		if err != nil {
 			check.ExitError(err)
 		}
+	}
 
-		pr.Perfdata.Add(&perfData)
+	return thresholds
+}
 
-		aggregatedResult.AddSubcheck(pr)
+// Parses the thresholds given by the datastore command's flags, including the thresholds on days until full.
+func parseDatastoreFlags() datastoreThresholds {
+	if datastoreForecast {
+		parseDatastoreForecastThresholds()
 	}
 
-	dbConnection.Close()
+	return parseDatastoreThresholds(datastoreWarning, datastoreCritical,
+		datastoreFreeWarning, datastoreFreeCritical,
+		datastoreProvisionedWarning, datastoreProvisionedCritical)
+}
 
-	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+// Computes the PartialResult including Perfdata for a single datastore.
+func datastorePartialResult(datastoreName string, capacity, freeSpace, uncommitted int64, thresholds datastoreThresholds) result.PartialResult {
+	perfData, state := processQueryResults(datastoreName, capacity, freeSpace, uncommitted, thresholds)
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Used storage for datastore %s: %d%% (%s free)",
+			datastoreName,
+			datastoreUsagePercent(capacity, freeSpace),
+			convert.BytesIEC(freeSpace).HumanReadable()),
+		state)
+	pr.Perfdata = append(pr.Perfdata, perfData...)
+
+	return pr
+}
+
+// Calculates the used capacity of a datastore in percent.
+func datastoreUsagePercent(capacity, freeSpace int64) int64 {
+	if capacity == 0 {
+		return 0
+	}
+
+	return (capacity - freeSpace) * 100 / capacity
 }
 
 // Computes Perfdata, check result based on the queried data.
-func processQueryResults(datastore string, capacity, freeSpace int64) (perfdata.Perfdata, int) {
+//
+// If thresholds on free space are given, they replace the thresholds in percent.
+func processQueryResults(datastore string, capacity, freeSpace, uncommitted int64, thresholds datastoreThresholds) (perfdata.PerfdataList, int) {
 	// calculate percentage usage for check result decision.
-	datastoreUsagePercent := int64(0)
-	if capacity != 0 {
-		datastoreUsagePercent = (capacity - freeSpace) * 100 / capacity
-	}
+	usagePercent := datastoreUsagePercent(capacity, freeSpace)
+	freeMode := thresholds.freeWarn != nil || thresholds.freeCrit != nil
 
 	// Add Perfdata.
+	var perfData perfdata.PerfdataList
+
 	// percentage usage.
-	perfData := perfdata.Perfdata{
+	usagePerfdata := &perfdata.Perfdata{
 		Label: datastore + "_used",
-		Value: datastoreUsagePercent,
+		Value: usagePercent,
 		Uom:   "%",
-		Warn:  datastoreWarnThreshold,
-		Crit:  datastoreCritThreshold,
+		Min:   0,
+		Max:   100,
+	}
+	// free space in bytes.
+	freePerfdata := &perfdata.Perfdata{
+		Label: datastore + "_free",
+		Value: freeSpace,
+		Uom:   "B",
+		Min:   0,
+		Max:   capacity,
 	}
 
 	// Decide on check result state.
 	statusCode := check.OK
 
-	if datastoreWarnThreshold.DoesViolate(float64(datastoreUsagePercent)) {
+	if freeMode {
+		freePerfdata.Warn = thresholds.freeWarn
+		freePerfdata.Crit = thresholds.freeCrit
+
+		if thresholds.freeWarn != nil && thresholds.freeWarn.DoesViolate(float64(freeSpace)) {
+			statusCode = check.Warning
+		}
+
+		if thresholds.freeCrit != nil && thresholds.freeCrit.DoesViolate(float64(freeSpace)) {
+			statusCode = check.Critical
+		}
+	} else {
+		usagePerfdata.Warn = thresholds.warn
+		usagePerfdata.Crit = thresholds.crit
+
+		statusCode = internal.EvaluateThresholds(float64(usagePercent), thresholds.warn, thresholds.crit)
+	}
+
+	perfData.Add(usagePerfdata)
+	// used space in bytes.
+	perfData.Add(&perfdata.Perfdata{
+		Label: datastore + "_used_bytes",
+		Value: capacity - freeSpace,
+		Uom:   "B",
+		Min:   0,
+		Max:   capacity,
+	})
+	perfData.Add(freePerfdata)
+	// capacity in bytes.
+	perfData.Add(&perfdata.Perfdata{
+		Label: datastore + "_capacity",
+		Value: capacity,
+		Uom:   "B",
+		Min:   0,
+	})
+
+	// provisioned space in percent.
+	provisionedPerfdata, provisionedStatusCode := processProvisioned(datastore, capacity, freeSpace, uncommitted, thresholds)
+	perfData.Add(provisionedPerfdata)
+
+	statusCode = result.WorstState(statusCode, provisionedStatusCode)
+
+	return perfData, statusCode
+}
+
+// Computes Perfdata, check result for the provisioned space of a datastore,
+// including space not yet committed by thin-provisioned disks.
+func processProvisioned(datastore string, capacity, freeSpace, uncommitted int64, thresholds datastoreThresholds) (*perfdata.Perfdata, int) {
+	provisionedPercent := int64(0)
+	if capacity != 0 {
+		provisionedPercent = (capacity - freeSpace + uncommitted) * 100 / capacity
+	}
+
+	// Decide on check result state, thresholds are optional.
+	statusCode := check.OK
+
+	if thresholds.provisionedWarn != nil && thresholds.provisionedWarn.DoesViolate(float64(provisionedPercent)) {
 		statusCode = check.Warning
 	}
 
-	if datastoreCritThreshold.DoesViolate(float64(datastoreUsagePercent)) {
+	if thresholds.provisionedCrit != nil && thresholds.provisionedCrit.DoesViolate(float64(provisionedPercent)) {
 		statusCode = check.Critical
 	}
 
-	return perfData, statusCode
+	return &perfdata.Perfdata{
+		Label: datastore + "_provisioned",
+		Value: provisionedPercent,
+		Uom:   "%",
+		Warn:  thresholds.provisionedWarn,
+		Crit:  thresholds.provisionedCrit,
+		Min:   0,
+	}, statusCode
 }
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/convert"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var datastoreCluster string
+var datastoreClusterWarning string
+var datastoreClusterCritical string
+var datastoreClusterFreeWarning string
+var datastoreClusterFreeCritical string
+var datastoreClusterProvisionedWarning string
+var datastoreClusterProvisionedCritical string
+
+// datastoreClusterPod holds the aggregated storage space and member results of a single datastore cluster.
+type datastoreClusterPod struct {
+	name             string
+	totalCapacity    int64
+	totalFreeSpace   int64
+	totalUncommitted int64
+	members          []result.PartialResult
+}
+
+// datastoreClusterCmd represents the datastore-cluster command.
+var datastoreClusterCmd = &cobra.Command{
+	Use:   "datastore-cluster",
+	Short: "Checks the aggregated storage space of a datastore cluster and its member datastores",
+	Long: `Checks the aggregated storage space of all member datastores of the datastore cluster
+(storage pod) given by --datastore-cluster in the vCenter given by --machine, as well as the
+storage space of each member datastore.
+
+Thresholds are evaluated the same way as for the datastore command.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryDatastoreCluster()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(datastoreClusterCmd)
+
+	datastoreClusterCmd.Flags().StringVarP(&datastoreCluster, "datastore-cluster", "s", "", "Datastore cluster to check")
+
+	datastoreClusterCmd.Flags().StringVarP(&datastoreClusterWarning, "warning", "w", "80", "Warning threshold in percent as Integer")
+	datastoreClusterCmd.Flags().StringVarP(&datastoreClusterCritical, "critical", "c", "90", "Critical threshold in percent as Integer")
+	datastoreClusterCmd.Flags().StringVar(&datastoreClusterFreeWarning, "warning-free", "", "Warning threshold for free space in bytes (\"less than X free\")")
+	datastoreClusterCmd.Flags().StringVar(&datastoreClusterFreeCritical, "critical-free", "", "Critical threshold for free space in bytes (\"less than X free\")")
+	datastoreClusterCmd.Flags().StringVar(&datastoreClusterProvisionedWarning, "provisioned-warning", "", "Warning threshold for provisioned space in percent as Integer")
+	datastoreClusterCmd.Flags().StringVar(&datastoreClusterProvisionedCritical, "provisioned-critical", "", "Critical threshold for provisioned space in percent as Integer")
+}
+
+// Query for storage space of the given datastore cluster and its members, exit with UNKNOWN on query errors.
+func queryDatastoreCluster() {
+	var (
+		err           error
+		podUUID       []byte
+		podName       string
+		datastoreName string
+		capacity      int64
+		freeSpace     int64
+		uncommitted   int64
+		pods          = map[string]*datastoreClusterPod{}
+		podUUIDs      []string
+	)
+
+	aggregatedResult := result.Overall{}
+
+	if datastoreCluster == "" {
+		check.ExitRaw(check.Unknown, "Error: --datastore-cluster flag is required")
+	}
+
+	// Parse thresholds from given flags.
+	thresholds := parseDatastoreThresholds(datastoreClusterWarning, datastoreClusterCritical,
+		datastoreClusterFreeWarning, datastoreClusterFreeCritical,
+		datastoreClusterProvisionedWarning, datastoreClusterProvisionedCritical)
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT sp.uuid, sp.object_name, o.object_name, ds.capacity, ds.free_space, COALESCE(ds.uncommitted, 0)
+		FROM datastore ds
+		INNER JOIN vcenter vc
+		ON ds.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON ds.uuid = o.uuid
+		INNER JOIN object sp
+		ON o.parent_uuid = sp.uuid
+		WHERE sp.object_name LIKE ?
+		AND sp.object_type = 'StoragePod'
+		AND vc.name LIKE ?
+		ORDER BY sp.object_name, o.object_name`,
+		datastoreCluster,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&podUUID, &podName, &datastoreName, &capacity, &freeSpace, &uncommitted)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		// Aggregate per storage pod, so pods matching the same name are not merged.
+		pod, ok := pods[string(podUUID)]
+		if !ok {
+			pod = &datastoreClusterPod{name: podName}
+			pods[string(podUUID)] = pod
+			podUUIDs = append(podUUIDs, string(podUUID))
+		}
+
+		pod.totalCapacity += capacity
+		pod.totalFreeSpace += freeSpace
+		pod.totalUncommitted += uncommitted
+		pod.members = append(pod.members, datastorePartialResult(datastoreName, capacity, freeSpace, uncommitted, thresholds))
+	}
+
+	dbConnection.Close()
+
+	if len(podUUIDs) == 0 {
+		check.ExitRaw(check.Unknown, "No datastores found for datastore cluster "+datastoreCluster+" in vCenter "+machine)
+	}
+
+	// Each aggregated datastore cluster comes first, followed by its members.
+	for _, uuid := range podUUIDs {
+		pod := pods[uuid]
+
+		aggregatedResult.AddSubcheck(datastoreClusterPartialResult(pod, thresholds))
+
+		for _, member := range pod.members {
+			aggregatedResult.AddSubcheck(member)
+		}
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult including Perfdata for the aggregate of a datastore cluster,
+// with labels prefixed to not collide with its member datastores.
+func datastoreClusterPartialResult(pod *datastoreClusterPod, thresholds datastoreThresholds) result.PartialResult {
+	perfData, state := processQueryResults("datastore_cluster_"+pod.name, pod.totalCapacity, pod.totalFreeSpace, pod.totalUncommitted, thresholds)
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Used storage for datastore cluster %s: %d%% (%s free)",
+			pod.name,
+			datastoreUsagePercent(pod.totalCapacity, pod.totalFreeSpace),
+			convert.BytesIEC(pod.totalFreeSpace).HumanReadable()),
+		state)
+	pr.Perfdata = append(pr.Perfdata, perfData...)
+
+	return pr
+}
@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+)
+
+var datastoreForecast bool
+var datastoreForecastWindow string
+var datastoreForecastDuration time.Duration
+var datastoreDaysWarning string
+var datastoreDaysCritical string
+var datastoreDaysWarnThreshold *check.Threshold
+var datastoreDaysCritThreshold *check.Threshold
+
+// Parses the forecast window and the thresholds on days until full from given flags.
+func parseDatastoreForecastThresholds() {
+	var err error
+
+	datastoreForecastDuration, err = internal.ParseAge(datastoreForecastWindow)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	datastoreDaysWarnThreshold, datastoreDaysCritThreshold = internal.ParseThresholds(datastoreDaysWarning+":", datastoreDaysCritical+":") // `:` is needed because warning/critical are reversed.
+}
+
+// Computes output, Perfdata and check result for the number of days until the datastore given by its uuid
+// is full, based on a linear trend of its used space over the forecast window, exit with UNKNOWN on query errors.
+func processForecast(dbConnection *sql.DB, datastoreName string, datastoreUUID []byte) (string, *perfdata.Perfdata, int) {
+	var (
+		tsCreated int64
+		tsFirst   int64
+		capacity  int64
+		freeSpace int64
+		xs        []float64
+		ys        []float64
+	)
+
+	rows, err := dbConnection.Query(`SELECT h.ts_created, h.capacity, h.free_space
+		FROM datastore_usage_history h
+		WHERE h.datastore_uuid = ?
+		AND h.ts_created >= ?
+		ORDER BY h.ts_created`,
+		datastoreUUID,
+		time.Now().Add(-datastoreForecastDuration).UnixMilli())
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&tsCreated, &capacity, &freeSpace)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		// Timestamps are relative to the first sample for numerical stability.
+		if len(xs) == 0 {
+			tsFirst = tsCreated
+		}
+
+		xs = append(xs, float64(tsCreated-tsFirst)/1000)
+		ys = append(ys, float64(capacity-freeSpace))
+	}
+
+	if len(xs) < 2 {
+		return "not enough history for forecast", nil, check.OK
+	}
+
+	// Least squares fit of the used space in bytes over time in seconds.
+	var sumX, sumY, sumXY, sumXX float64
+
+	for i := range xs {
+		sumX += xs[i]
+		sumY += ys[i]
+		sumXY += xs[i] * ys[i]
+		sumXX += xs[i] * xs[i]
+	}
+
+	n := float64(len(xs))
+	denominator := n*sumXX - sumX*sumX
+
+	if denominator == 0 {
+		return "not enough history for forecast", nil, check.OK
+	}
+
+	slope := (n*sumXY - sumX*sumY) / denominator
+
+	if slope <= 0 {
+		return "usage not growing", nil, check.OK
+	}
+
+	// Project from the latest sample.
+	daysUntilFull := (float64(capacity) - ys[len(ys)-1]) / slope / (24 * 60 * 60)
+
+	return fmt.Sprintf("full in %.1f days", daysUntilFull),
+		&perfdata.Perfdata{
+			Label: datastoreName + "_days_until_full",
+			Value: daysUntilFull,
+			Warn:  datastoreDaysWarnThreshold,
+			Crit:  datastoreDaysCritThreshold,
+			Min:   0,
+		},
+		internal.EvaluateThresholds(daysUntilFull, datastoreDaysWarnThreshold, datastoreDaysCritThreshold)
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var datastoreHealthDatastore string
+var datastoreHealthMaintenanceState string
+var datastoreHealthMountState string
+
+// datastoreHealthCmd represents the datastore-health command.
+var datastoreHealthCmd = &cobra.Command{
+	Use:   "datastore-health",
+	Short: "Checks accessibility, maintenance mode and mounts of all datastores or a singular, specified datastore",
+	Long: `Checks accessibility, maintenance mode and the number of mounting hosts of all datastores
+of the vCenter given by --machine, or of a singular datastore given by --datastore.
+
+An inaccessible datastore results in CRITICAL. A datastore mounted on fewer hosts than the
+clusters of its mounting hosts consist of results in the state given by --mount-state.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryDatastoreHealth()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(datastoreHealthCmd)
+
+	datastoreHealthCmd.Flags().StringVarP(&datastoreHealthDatastore, "datastore", "s", "", "Datastore to check, all datastores of the vCenter if empty")
+	datastoreHealthCmd.Flags().StringVar(&datastoreHealthMaintenanceState, "maintenance-state", "warning", "State if the datastore is in maintenance mode")
+	datastoreHealthCmd.Flags().StringVar(&datastoreHealthMountState, "mount-state", "warning", "State if the datastore is not mounted on all hosts of the cluster")
+}
+
+// Query for the health of the given vCenter's datastores, exit with UNKNOWN on query errors.
+func queryDatastoreHealth() {
+	var (
+		err             error
+		datastoreName   string
+		accessible      string
+		maintenanceMode string
+		mountedHosts    int64
+		clusterHosts    int64
+	)
+
+	aggregatedResult := result.Overall{}
+
+	datastoreFilter := datastoreHealthDatastore
+	if datastoreFilter == "" {
+		datastoreFilter = "%"
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	// The cluster hosts are all hosts sharing a parent (cluster) with any of the mounting hosts.
+	rows, err := dbConnection.Query(`SELECT o.object_name,
+		ds.is_accessible,
+		COALESCE(ds.maintenance_mode, 'normal'),
+		(SELECT COUNT(*)
+			FROM datastore_host_mount m
+			WHERE m.datastore_uuid = ds.uuid
+			AND m.mounted = 'y'),
+		(SELECT COUNT(DISTINCT ch.uuid)
+			FROM datastore_host_mount m
+			INNER JOIN object h
+			ON m.host_uuid = h.uuid
+			INNER JOIN object ch
+			ON ch.parent_uuid = h.parent_uuid
+			AND ch.object_type = 'HostSystem'
+			WHERE m.datastore_uuid = ds.uuid)
+		FROM datastore ds
+		INNER JOIN vcenter vc
+		ON ds.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON ds.uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		AND vc.name LIKE ?
+		ORDER BY o.object_name`,
+		datastoreFilter,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&datastoreName, &accessible, &maintenanceMode, &mountedHosts, &clusterHosts)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		aggregatedResult.AddSubcheck(processDatastoreHealth(datastoreName, accessible, maintenanceMode, mountedHosts, clusterHosts))
+	}
+
+	dbConnection.Close()
+
+	if len(aggregatedResult.PartialResults) == 0 {
+		check.ExitRaw(check.Unknown, "No datastores found for vCenter "+machine)
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult and Perfdata for the health of a single datastore.
+func processDatastoreHealth(datastoreName, accessible, maintenanceMode string, mountedHosts, clusterHosts int64) result.PartialResult {
+	pr := result.PartialResult{
+		Output: "Datastore " + datastoreName,
+	}
+
+	if accessible == "y" {
+		pr.AddSubcheck(internal.StatePartialResult("Datastore is accessible", check.OK))
+	} else {
+		pr.AddSubcheck(internal.StatePartialResult("Datastore is not accessible", check.Critical))
+	}
+
+	if maintenanceMode == "normal" {
+		pr.AddSubcheck(internal.StatePartialResult("Datastore is not in maintenance mode", check.OK))
+	} else {
+		pr.AddSubcheck(internal.StatePartialResult("Datastore maintenance mode is "+maintenanceMode,
+			internal.ParseState(datastoreHealthMaintenanceState)))
+	}
+
+	mountState := check.OK
+	if mountedHosts < clusterHosts {
+		mountState = internal.ParseState(datastoreHealthMountState)
+	}
+
+	pr.AddSubcheck(internal.StatePartialResult(
+		fmt.Sprintf("Datastore is mounted on %d of %d cluster hosts", mountedHosts, clusterHosts),
+		mountState))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: datastoreName + "_mounted_hosts",
+		Value: mountedHosts,
+		Min:   0,
+		Max:   clusterHosts,
+	})
+
+	return pr
+}
@@ -0,0 +1,239 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var diskIOWarning string
+var diskIOCritical string
+var diskIOType string
+var diskIOWarnThreshold *check.Threshold
+var diskIOCritThreshold *check.Threshold
+
+// virtualDiskIO holds the virtual disk performance counters of a single disk or datastore.
+type virtualDiskIO struct {
+	name         string
+	label        string
+	readIOPS     int64
+	writeIOPS    int64
+	readKBps     int64
+	writeKBps    int64
+	readLatency  int64
+	writeLatency int64
+}
+
+// diskIOCmd represents the disk-io command.
+var diskIOCmd = &cobra.Command{
+	Use:   "disk-io",
+	Short: "Checks latency, IOPS and throughput of virtual disks",
+	Long: `Checks latency, IOPS and throughput of each virtual disk of the virtual machine given by
+--machine, or aggregated over all virtual disks backed by the datastore given by --machine
+with --type datastore, based on the latest 5-minute rollup. Rollups older than two intervals
+are ignored.
+
+Thresholds are evaluated on the higher of read and write latency in milliseconds.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryDiskIO()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diskIOCmd)
+
+	diskIOCmd.Flags().StringVarP(&diskIOWarning, "warning", "w", "20", "Warning threshold for latency in ms as Integer")
+	diskIOCmd.Flags().StringVarP(&diskIOCritical, "critical", "c", "50", "Critical threshold for latency in ms as Integer")
+	diskIOCmd.Flags().StringVarP(&diskIOType, "type", "t", "vm", "Type of the machine to check (vm, datastore)")
+}
+
+// Query for virtual disk performance of the given VM or datastore, exit with UNKNOWN on query errors.
+func queryDiskIO() {
+	var query string
+
+	aggregatedResult := result.Overall{}
+
+	// Parse thresholds from given flags.
+	diskIOWarnThreshold, diskIOCritThreshold = internal.ParseThresholds(diskIOWarning, diskIOCritical)
+
+	switch diskIOType {
+	case "vm":
+		query = `SELECT o.object_name, c.instance, pc.name, c.ts_last, COALESCE(c.value_last, 0)
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN object o
+		ON c.object_uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		AND pc.group_name = 'virtualDisk'
+		AND pc.name IN ('numberReadAveraged', 'numberWriteAveraged', 'read', 'write', 'totalReadLatency', 'totalWriteLatency')
+		AND c.instance != ''
+		ORDER BY o.object_name, c.instance`
+	case "datastore":
+		// Map each counter instance (e.g. scsi0:1) to its virtual disk via the disk's controller
+		// (e.g. "SCSI controller 0") and unit number, so only disks backed by the datastore count.
+		query = `SELECT dso.object_name, c.instance, pc.name, c.ts_last, COALESCE(c.value_last, 0)
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN vm_hardware vh
+		ON c.object_uuid = vh.vm_uuid
+		INNER JOIN vm_hardware ctrl
+		ON vh.vm_uuid = ctrl.vm_uuid
+		AND vh.controller_key = ctrl.hardware_key
+		INNER JOIN vm_disk vd
+		ON vh.vm_uuid = vd.vm_uuid
+		AND vh.hardware_key = vd.hardware_key
+		INNER JOIN object dso
+		ON vd.datastore_uuid = dso.uuid
+		WHERE dso.object_name LIKE ?
+		AND pc.group_name = 'virtualDisk'
+		AND pc.name IN ('numberReadAveraged', 'numberWriteAveraged', 'read', 'write', 'totalReadLatency', 'totalWriteLatency')
+		AND c.instance = CONCAT(LOWER(SUBSTRING_INDEX(ctrl.hardware_label, ' ', 1)),
+		SUBSTRING_INDEX(ctrl.hardware_label, ' ', -1), ':', vh.unit_number)
+		ORDER BY dso.object_name, c.instance`
+	default:
+		check.ExitError(fmt.Errorf("invalid type: %s", diskIOType))
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(query, machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	disks := collectVirtualDiskIO(rows)
+
+	dbConnection.Close()
+
+	if len(disks) == 0 {
+		check.ExitRaw(check.Unknown, "No current virtual disk counters found for "+diskIOType+" "+machine)
+	}
+
+	for _, disk := range disks {
+		aggregatedResult.AddSubcheck(processVirtualDiskIO(disk))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Reads the virtual disk counters from the query results, per disk of each VM or aggregated per datastore,
+// skipping stale rollups, exit with UNKNOWN on scan errors.
+func collectVirtualDiskIO(rows *sql.Rows) []*virtualDiskIO {
+	var (
+		objectName  string
+		instance    string
+		counterName string
+		tsLast      int64
+		value       int64
+		disks       = map[string]*virtualDiskIO{}
+		collected   []*virtualDiskIO
+	)
+
+	for rows.Next() {
+		// Read row into variables.
+		err := rows.Scan(&objectName, &instance, &counterName, &tsLast, &value)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		if !counterIsCurrent(tsLast) {
+			continue
+		}
+
+		name := "Datastore " + objectName
+		label := objectName
+
+		if diskIOType == "vm" {
+			name = "VM " + objectName + " disk " + instance
+			label = objectName + "_" + instance
+		}
+
+		if _, ok := disks[name]; !ok {
+			disks[name] = &virtualDiskIO{name: name, label: label}
+			collected = append(collected, disks[name])
+		}
+
+		addVirtualDiskCounter(disks[name], counterName, value)
+	}
+
+	return collected
+}
+
+// Adds a counter value to the given disk, summing up rates and keeping the highest latencies.
+func addVirtualDiskCounter(disk *virtualDiskIO, counterName string, value int64) {
+	switch counterName {
+	case "numberReadAveraged":
+		disk.readIOPS += value
+	case "numberWriteAveraged":
+		disk.writeIOPS += value
+	case "read":
+		disk.readKBps += value
+	case "write":
+		disk.writeKBps += value
+	case "totalReadLatency":
+		disk.readLatency = max(disk.readLatency, value)
+	case "totalWriteLatency":
+		disk.writeLatency = max(disk.writeLatency, value)
+	}
+}
+
+// Computes the PartialResult and Perfdata for a single disk or datastore.
+func processVirtualDiskIO(disk *virtualDiskIO) result.PartialResult {
+	latency := max(disk.readLatency, disk.writeLatency)
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("%s: latency %dms read, %dms write, %d/%d IOPS, %d/%dKB/s read/write",
+			disk.name,
+			disk.readLatency,
+			disk.writeLatency,
+			disk.readIOPS,
+			disk.writeIOPS,
+			disk.readKBps,
+			disk.writeKBps),
+		internal.EvaluateThresholds(float64(latency), diskIOWarnThreshold, diskIOCritThreshold))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_read_latency",
+		Value: disk.readLatency,
+		Uom:   "ms",
+		Warn:  diskIOWarnThreshold,
+		Crit:  diskIOCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_write_latency",
+		Value: disk.writeLatency,
+		Uom:   "ms",
+		Warn:  diskIOWarnThreshold,
+		Crit:  diskIOCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_read_iops",
+		Value: disk.readIOPS,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_write_iops",
+		Value: disk.writeIOPS,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_read_bytes_per_second",
+		Value: disk.readKBps * 1024,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: disk.label + "_write_bytes_per_second",
+		Value: disk.writeKBps * 1024,
+	})
+
+	return pr
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
 	"github.com/spf13/cobra"
 )
 
@@ -11,11 +14,17 @@ var hbaWarning string
 var hbaCritical string
 var hbaWarnThreshold *check.Threshold
 var hbaCritThreshold *check.Threshold
+var hbaOfflineState string
 
 // hbaCmd represents the hba command.
 var hbaCmd = &cobra.Command{
 	Use:   "hba",
 	Short: "Checks attached HBAs",
+	Long: `Checks the status of each storage adapter (HBA) of the ESXi host given by --machine,
+as well as the number of online adapters.
+
+Offline adapters result in the state given by --offline-state, adapters in unknown
+status result in UNKNOWN.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryHba()
 	},
@@ -24,16 +33,23 @@ var hbaCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(hbaCmd)
 
-	hbaCmd.Flags().StringVarP(&hbaWarning, "warning", "w", "2", "Warning threshold as Integer (\"less than X available\")")
-	hbaCmd.Flags().StringVarP(&hbaCritical, "critical", "c", "1", "Critical threshold as Integer (\"less than X available\")")
+	hbaCmd.Flags().StringVarP(&hbaWarning, "warning", "w", "2", "Warning threshold as Integer (\"less than X online\")")
+	hbaCmd.Flags().StringVarP(&hbaCritical, "critical", "c", "1", "Critical threshold as Integer (\"less than X online\")")
+	hbaCmd.Flags().StringVar(&hbaOfflineState, "offline-state", "critical", "State if an HBA is offline")
 }
 
 func queryHba() {
 	var (
-		hardwareNumHBAs int16
-		err             error
+		err        error
+		device     string
+		model      string
+		driver     string
+		status     string
+		hbasOnline int
 	)
 
+	aggregatedResult := result.Overall{}
+
 	// Parse thresholds from given flags.
 	hbaWarnThreshold, err = check.ParseThreshold(hbaWarning + ":") // `:` is needed because warning/critical are reversed.
 	if err != nil {
@@ -47,35 +63,64 @@ func queryHba() {
 
 	dbConnection := internal.DBConnection(host, port, username, password, database)
 
-	err = dbConnection.QueryRow(`SELECT hardware_num_hba 
-        FROM host_system 
-        WHERE host_system.host_name LIKE ?`,
-		machine).Scan(&hardwareNumHBAs)
+	rows, err := dbConnection.Query(`SELECT hba.device, hba.model, hba.driver, hba.status
+		FROM host_hba hba
+		INNER JOIN host_system hs
+		ON hba.host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		ORDER BY hba.device`,
+		machine)
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	pl.Add(&perfdata.Perfdata{
-		Label: "hbas",
-		Value: hardwareNumHBAs,
-		Warn:  hbaWarnThreshold,
-		Crit:  hbaCritThreshold,
-	})
+	defer rows.Close()
 
-	// Decide on check result state.
-	statusCode := check.OK
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&device, &model, &driver, &status)
+		if err != nil {
+			check.ExitError(err)
+		}
 
-	if hbaWarnThreshold.DoesViolate(float64(hardwareNumHBAs)) {
-		statusCode = check.Warning
-	}
+		adapterUp := 0
+		statusState := check.Unknown
 
-	if hbaCritThreshold.DoesViolate(float64(hardwareNumHBAs)) {
-		statusCode = check.Critical
+		switch status {
+		case "online":
+			statusState = check.OK
+			adapterUp = 1
+			hbasOnline++
+		case "offline":
+			statusState = internal.ParseState(hbaOfflineState)
+		case "unbound":
+			statusState = check.OK
+		}
+
+		pr := internal.StatePartialResult(fmt.Sprintf("HBA %s (%s, driver %s) is %s", device, model, driver, status), statusState)
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: device + "_online",
+			Value: adapterUp,
+			Min:   0,
+			Max:   1,
+		})
+		aggregatedResult.AddSubcheck(pr)
 	}
 
 	dbConnection.Close()
-	check.Exitf(statusCode,
-		"Number of HBAs: %d | %s",
-		hardwareNumHBAs,
-		pl.String())
+
+	// Number of online HBAs.
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Number of HBAs online: %d", hbasOnline),
+		internal.EvaluateThresholds(float64(hbasOnline), hbaWarnThreshold, hbaCritThreshold))
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "hbas",
+		Value: hbasOnline,
+		Warn:  hbaWarnThreshold,
+		Crit:  hbaCritThreshold,
+	})
+	aggregatedResult.AddSubcheck(pr)
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
 }
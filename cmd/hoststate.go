@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var hostStateDisconnectedState string
+var hostStateNotRespondingState string
+var hostStateMaintenanceState string
+var hostStateStandbyState string
+var hostStatePoweredOffState string
+
+// hostStateCmd represents the host-state command.
+var hostStateCmd = &cobra.Command{
+	Use:   "host-state",
+	Short: "Checks connection state, power state and maintenance mode of a host",
+	Long: `Checks connection state, power state and maintenance mode of the ESXi host given by --machine.
+
+States can be given by name (ok, warning, critical, unknown) or by their numeric value,
+e.g. --maintenance-state ok during patch windows.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryHostState()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(hostStateCmd)
+
+	hostStateCmd.Flags().StringVar(&hostStateDisconnectedState, "disconnected-state", "critical", "State if the host is disconnected")
+	hostStateCmd.Flags().StringVar(&hostStateNotRespondingState, "not-responding-state", "critical", "State if the host is not responding")
+	hostStateCmd.Flags().StringVar(&hostStateMaintenanceState, "maintenance-state", "warning", "State if the host is in maintenance mode")
+	hostStateCmd.Flags().StringVar(&hostStateStandbyState, "standby-state", "warning", "State if the host is in standby")
+	hostStateCmd.Flags().StringVar(&hostStatePoweredOffState, "powered-off-state", "critical", "State if the host is powered off")
+}
+
+// Query for connection state, power state and maintenance mode of the given machine, exit with UNKNOWN on query errors.
+func queryHostState() {
+	var (
+		err             error
+		connectionState string
+		powerState      string
+		maintenanceMode string
+		uptime          int64
+	)
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err = dbConnection.QueryRow(`SELECT hs.runtime_connection_state,
+		hs.runtime_power_state,
+		hs.runtime_in_maintenance_mode,
+		COALESCE(hqs.uptime, 0)
+		FROM host_system hs
+		LEFT JOIN host_quick_stats hqs
+		ON hs.uuid = hqs.uuid
+		WHERE hs.host_name LIKE ?`,
+		machine).Scan(&connectionState, &powerState, &maintenanceMode, &uptime)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+
+	aggregatedResult := result.Overall{}
+
+	// Connection state.
+	connectionStatusCode := check.Unknown
+
+	switch connectionState {
+	case "connected":
+		connectionStatusCode = check.OK
+	case "disconnected":
+		connectionStatusCode = internal.ParseState(hostStateDisconnectedState)
+	case "notResponding":
+		connectionStatusCode = internal.ParseState(hostStateNotRespondingState)
+	}
+
+	aggregatedResult.AddSubcheck(internal.StatePartialResult("Connection state is "+connectionState, connectionStatusCode))
+
+	// Power state.
+	powerStatusCode := check.Unknown
+
+	switch powerState {
+	case "poweredOn":
+		powerStatusCode = check.OK
+	case "standBy":
+		powerStatusCode = internal.ParseState(hostStateStandbyState)
+	case "poweredOff":
+		powerStatusCode = internal.ParseState(hostStatePoweredOffState)
+	}
+
+	pr := internal.StatePartialResult("Power state is "+powerState, powerStatusCode)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "uptime",
+		Value: uptime,
+		Uom:   "s",
+	})
+	aggregatedResult.AddSubcheck(pr)
+
+	// Maintenance mode.
+	if maintenanceMode == "y" {
+		aggregatedResult.AddSubcheck(internal.StatePartialResult("Host is in maintenance mode", internal.ParseState(hostStateMaintenanceState)))
+	} else {
+		aggregatedResult.AddSubcheck(internal.StatePartialResult("Host is not in maintenance mode", check.OK))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
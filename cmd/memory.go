@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
@@ -11,11 +14,18 @@ var memoryWarning string
 var memoryCritical string
 var memoryWarnThreshold *check.Threshold
 var memoryCritThreshold *check.Threshold
+var memoryWindow string
+var memoryAggregate string
 
 // memoryCmd represents the memory command.
 var memoryCmd = &cobra.Command{
 	Use:   "memory",
 	Short: "Checks memory usage",
+	Long: `Checks the memory usage of the ESXi host given by --machine.
+
+By default, the current quick stats are evaluated. With --window, the 5-minute rollups of
+the memory usage counter within the window (5m to 25m) are aggregated by --aggregate
+(avg or max) instead, to avoid alerts on short spikes.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryMemory()
 	},
@@ -26,6 +36,8 @@ func init() {
 
 	memoryCmd.Flags().StringVarP(&memoryWarning, "warning", "w", "80", "Warning threshold in percent as Integer")
 	memoryCmd.Flags().StringVarP(&memoryCritical, "critical", "c", "90", "Critical threshold in percent as Integer")
+	memoryCmd.Flags().StringVar(&memoryWindow, "window", "", "Window of performance counter rollups to evaluate instead of quick stats, e.g. 15m")
+	memoryCmd.Flags().StringVar(&memoryAggregate, "aggregate", "avg", "Aggregation of performance counter rollups (avg, max)")
 }
 
 // Query for memory usage of the given machine, exit with UNKNOWN on query errors.
@@ -33,9 +45,16 @@ func queryMemory() {
 	var (
 		overallMemoryUsageMB int64
 		hardwareMemorySizeMB int64
+		window               time.Duration
+		windowOutput         string
 		err                  error
 	)
 
+	// Validate the window before querying.
+	if memoryWindow != "" {
+		window = parseCounterWindow(memoryWindow, memoryAggregate)
+	}
+
 	// Parse thresholds from given flags.
 	memoryWarnThreshold, err = check.ParseThreshold(memoryWarning)
 	if err != nil {
@@ -63,6 +82,14 @@ func queryMemory() {
 	// calculate percentage usage for check result decision.
 	memoryUsagePercent := overallMemoryUsageMB * 100 / hardwareMemorySizeMB
 
+	if memoryWindow != "" {
+		// mem.usage is reported in hundredths of a percent.
+		usage := queryHostCounter(dbConnection, "mem", "usage", window, memoryAggregate) / 100
+		windowOutput = fmt.Sprintf(" (%s over %s)", memoryAggregate, memoryWindow)
+		memoryUsagePercent = int64(usage)
+		overallMemoryUsageMB = int64(usage * float64(hardwareMemorySizeMB) / 100)
+	}
+
 	// Add Perfdata.
 	// total usage.
 	pl.Add(&perfdata.Perfdata{
@@ -92,8 +119,9 @@ func queryMemory() {
 
 	dbConnection.Close()
 	check.Exitf(statusCode,
-		"Total Memory usage is %dGB (%d%%) | %s",
+		"Total Memory usage is %dGB (%d%%)%s | %s",
 		overallMemoryUsageMB/1024,
 		memoryUsagePercent,
+		windowOutput,
 		pl.String())
 }
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var memoryReclamationType string
+var memoryBalloonWarning string
+var memoryBalloonCritical string
+var memorySwappedWarning string
+var memorySwappedCritical string
+var memoryCompressedWarning string
+var memoryCompressedCritical string
+var memorySharedWarning string
+var memorySharedCritical string
+
+// memoryReclamationCmd represents the memory-reclamation command.
+var memoryReclamationCmd = &cobra.Command{
+	Use:   "memory-reclamation",
+	Short: "Checks ballooned, swapped, compressed and shared memory of a VM or host",
+	Long: `Checks ballooned, swapped, compressed and shared memory of the virtual machine given by
+--machine, or summed up over all virtual machines running on the ESXi host given by --machine
+with --type host.
+
+With --type host, the quick stats of the VMs are summed up, as vSphereDB's host quick stats
+do not include ballooned, swapped, compressed or shared memory.
+
+All thresholds are given in MB, thresholds on shared memory are optional.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryMemoryReclamation()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(memoryReclamationCmd)
+
+	memoryReclamationCmd.Flags().StringVarP(&memoryReclamationType, "type", "t", "vm", "Type of the machine to check (vm, host)")
+	memoryReclamationCmd.Flags().StringVar(&memoryBalloonWarning, "balloon-warning", "0", "Warning threshold for ballooned memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memoryBalloonCritical, "balloon-critical", "1024", "Critical threshold for ballooned memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memorySwappedWarning, "swapped-warning", "0", "Warning threshold for swapped memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memorySwappedCritical, "swapped-critical", "1024", "Critical threshold for swapped memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memoryCompressedWarning, "compressed-warning", "0", "Warning threshold for compressed memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memoryCompressedCritical, "compressed-critical", "1024", "Critical threshold for compressed memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memorySharedWarning, "shared-warning", "", "Warning threshold for shared memory in MB as Integer")
+	memoryReclamationCmd.Flags().StringVar(&memorySharedCritical, "shared-critical", "", "Critical threshold for shared memory in MB as Integer")
+}
+
+// Query for memory reclamation of the given VM or host, exit with UNKNOWN on query errors.
+func queryMemoryReclamation() {
+	var (
+		err          error
+		query        string
+		numVMs       int64
+		balloonedMB  float64
+		swappedMB    float64
+		compressedMB float64
+		sharedMB     float64
+	)
+
+	aggregatedResult := result.Overall{}
+
+	switch memoryReclamationType {
+	case "vm":
+		query = `SELECT COUNT(*),
+		COALESCE(SUM(vqs.ballooned_memory_mb), 0),
+		COALESCE(SUM(vqs.swapped_memory_mb), 0),
+		COALESCE(SUM(vqs.compressed_memory_kb), 0) / 1024,
+		COALESCE(SUM(vqs.shared_memory_mb), 0)
+		FROM vm_quick_stats vqs
+		INNER JOIN object o
+		ON vqs.uuid = o.uuid
+		WHERE o.object_name LIKE ?`
+	case "host":
+		query = `SELECT COUNT(*),
+		COALESCE(SUM(vqs.ballooned_memory_mb), 0),
+		COALESCE(SUM(vqs.swapped_memory_mb), 0),
+		COALESCE(SUM(vqs.compressed_memory_kb), 0) / 1024,
+		COALESCE(SUM(vqs.shared_memory_mb), 0)
+		FROM vm_quick_stats vqs
+		INNER JOIN virtual_machine vm
+		ON vqs.uuid = vm.uuid
+		INNER JOIN host_system hs
+		ON vm.runtime_host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?`
+	default:
+		check.ExitError(fmt.Errorf("invalid type: %s", memoryReclamationType))
+	}
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err = dbConnection.QueryRow(query, machine).Scan(&numVMs, &balloonedMB, &swappedMB, &compressedMB, &sharedMB)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+
+	if numVMs == 0 {
+		check.ExitRaw(check.Unknown, "No VMs found for "+memoryReclamationType+" "+machine)
+	}
+
+	aggregatedResult.AddSubcheck(memoryReclamationResult("ballooned", "Ballooned memory", balloonedMB, memoryBalloonWarning, memoryBalloonCritical))
+	aggregatedResult.AddSubcheck(memoryReclamationResult("swapped", "Swapped memory", swappedMB, memorySwappedWarning, memorySwappedCritical))
+	aggregatedResult.AddSubcheck(memoryReclamationResult("compressed", "Compressed memory", compressedMB, memoryCompressedWarning, memoryCompressedCritical))
+	aggregatedResult.AddSubcheck(memoryReclamationResult("shared", "Shared memory", sharedMB, memorySharedWarning, memorySharedCritical))
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult and Perfdata for a single memory metric given in MB,
+// thresholds are only evaluated if given.
+func memoryReclamationResult(label, description string, valueMB float64, warning, critical string) result.PartialResult {
+	var (
+		err           error
+		warnThreshold *check.Threshold
+		critThreshold *check.Threshold
+	)
+
+	if warning != "" {
+		warnThreshold, err = check.ParseThreshold(warning)
+		if err != nil {
+			check.ExitError(err)
+		}
+	}
+
+	if critical != "" {
+		critThreshold, err = check.ParseThreshold(critical)
+		if err != nil {
+			check.ExitError(err)
+		}
+	}
+
+	state := check.OK
+
+	if warnThreshold != nil && warnThreshold.DoesViolate(valueMB) {
+		state = check.Warning
+	}
+
+	if critThreshold != nil && critThreshold.DoesViolate(valueMB) {
+		state = check.Critical
+	}
+
+	pr := internal.StatePartialResult(fmt.Sprintf("%s is %.0fMB", description, valueMB), state)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: label,
+		Value: int64(valueMB * 1024 * 1024), // Report in Bytes.
+		Uom:   "B",
+		Warn:  megabytesToBytesThreshold(warnThreshold),
+		Crit:  megabytesToBytesThreshold(critThreshold),
+		Min:   0,
+	})
+
+	return pr
+}
+
+// Converts a threshold given in MB into bytes, to match the Perfdata's unit.
+func megabytesToBytesThreshold(threshold *check.Threshold) *check.Threshold {
+	if threshold == nil {
+		return nil
+	}
+
+	return &check.Threshold{
+		Inside: threshold.Inside,
+		Lower:  threshold.Lower * 1024 * 1024,
+		Upper:  threshold.Upper * 1024 * 1024,
+	}
+}
@@ -0,0 +1,256 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var networkWarning string
+var networkCritical string
+var networkDropWarning string
+var networkDropCritical string
+var networkType string
+var networkWindow string
+var networkAggregate string
+var networkWarnThreshold *check.Threshold
+var networkCritThreshold *check.Threshold
+var networkDropWarnThreshold *check.Threshold
+var networkDropCritThreshold *check.Threshold
+
+// networkInterface holds the network performance counters of a single vmnic or vNIC.
+type networkInterface struct {
+	name        string
+	linkSpeedMb int64
+	rxKBps      float64
+	txKBps      float64
+	rxPackets   float64
+	txPackets   float64
+	rxDropped   float64
+	txDropped   float64
+}
+
+// networkCmd represents the network command.
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Checks network throughput and packet drops of a host's vmnics or a VM's vNICs",
+	Long: `Checks throughput and dropped packets of each physical NIC (vmnic) of the ESXi host given
+by --machine, or of each virtual NIC of the virtual machine given by --machine with --type vm.
+
+The 5-minute rollups of the counters within --window (5m to 25m) are aggregated by
+--aggregate (avg or max). The utilization relative to the negotiated link speed is only
+available for vmnics.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryNetwork()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(networkCmd)
+
+	networkCmd.Flags().StringVarP(&networkWarning, "warning", "w", "80", "Warning threshold for utilization in percent as Integer")
+	networkCmd.Flags().StringVarP(&networkCritical, "critical", "c", "90", "Critical threshold for utilization in percent as Integer")
+	networkCmd.Flags().StringVar(&networkDropWarning, "drop-warning", "0.1", "Warning threshold for dropped packets in percent")
+	networkCmd.Flags().StringVar(&networkDropCritical, "drop-critical", "1", "Critical threshold for dropped packets in percent")
+	networkCmd.Flags().StringVarP(&networkType, "type", "t", "host", "Type of the machine to check (host, vm)")
+	networkCmd.Flags().StringVar(&networkWindow, "window", "15m", "Window of performance counter rollups to evaluate")
+	networkCmd.Flags().StringVar(&networkAggregate, "aggregate", "avg", "Aggregation of performance counter rollups (avg, max)")
+}
+
+// Query for network counters of the given host or VM, exit with UNKNOWN on query errors.
+func queryNetwork() {
+	var query string
+
+	aggregatedResult := result.Overall{}
+
+	window := parseCounterWindow(networkWindow, networkAggregate)
+
+	// Parse thresholds from given flags.
+	networkWarnThreshold, networkCritThreshold = internal.ParseThresholds(networkWarning, networkCritical)
+	networkDropWarnThreshold, networkDropCritThreshold = internal.ParseThresholds(networkDropWarning, networkDropCritical)
+
+	switch networkType {
+	case "host":
+		query = `SELECT c.instance, pc.name, COALESCE(pn.link_speed_mb, 0), c.ts_last,
+		c.value_last, c.value_minus1, c.value_minus2, c.value_minus3, c.value_minus4
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN host_system hs
+		ON c.object_uuid = hs.uuid
+		LEFT JOIN host_physical_nic pn
+		ON c.object_uuid = pn.host_uuid
+		AND c.instance = pn.device
+		WHERE hs.host_name LIKE ?
+		AND pc.group_name = 'net'
+		AND pc.name IN ('bytesRx', 'bytesTx', 'packetsRx', 'packetsTx', 'droppedRx', 'droppedTx')
+		AND c.instance != ''
+		ORDER BY c.instance`
+	case "vm":
+		query = `SELECT c.instance, pc.name, 0, c.ts_last,
+		c.value_last, c.value_minus1, c.value_minus2, c.value_minus3, c.value_minus4
+		FROM counter_300x5 c
+		INNER JOIN performance_counter pc
+		ON c.counter_key = pc.counter_key
+		AND c.vcenter_uuid = pc.vcenter_uuid
+		INNER JOIN object o
+		ON c.object_uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		AND o.object_type = 'VirtualMachine'
+		AND pc.group_name = 'net'
+		AND pc.name IN ('bytesRx', 'bytesTx', 'packetsRx', 'packetsTx', 'droppedRx', 'droppedTx')
+		AND c.instance != ''
+		ORDER BY c.instance`
+	default:
+		check.ExitError(fmt.Errorf("invalid type: %s", networkType))
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(query, machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	nics := collectNetworkInterfaces(rows, window)
+
+	dbConnection.Close()
+
+	if len(nics) == 0 {
+		check.ExitRaw(check.Unknown, "No network counters within the last "+networkWindow+" found for "+networkType+" "+machine)
+	}
+
+	for _, nic := range nics {
+		aggregatedResult.AddSubcheck(processNetworkInterface(nic))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Reads the network counters per interface from the query results and aggregates the samples
+// within the window, counters without samples within the window are skipped, exit with UNKNOWN on scan errors.
+func collectNetworkInterfaces(rows *sql.Rows, window time.Duration) []*networkInterface {
+	var (
+		instance    string
+		counterName string
+		linkSpeedMb int64
+		tsLast      int64
+		values      = make([]sql.NullInt64, counterMaxSamples)
+		nics        = map[string]*networkInterface{}
+		collected   []*networkInterface
+	)
+
+	for rows.Next() {
+		// Read row into variables.
+		err := rows.Scan(&instance, &counterName, &linkSpeedMb, &tsLast, &values[0], &values[1], &values[2], &values[3], &values[4])
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		samples := counterSamples(values, tsLast, window)
+		if len(samples) == 0 {
+			continue
+		}
+
+		if _, ok := nics[instance]; !ok {
+			nics[instance] = &networkInterface{name: instance, linkSpeedMb: linkSpeedMb}
+			collected = append(collected, nics[instance])
+		}
+
+		addNetworkCounter(nics[instance], counterName, aggregateSamples(samples, networkAggregate))
+	}
+
+	return collected
+}
+
+// Adds a counter value to the given network interface.
+func addNetworkCounter(nic *networkInterface, counterName string, value float64) {
+	switch counterName {
+	case "bytesRx":
+		nic.rxKBps = value
+	case "bytesTx":
+		nic.txKBps = value
+	case "packetsRx":
+		nic.rxPackets = value
+	case "packetsTx":
+		nic.txPackets = value
+	case "droppedRx":
+		nic.rxDropped = value
+	case "droppedTx":
+		nic.txDropped = value
+	}
+}
+
+// Computes the PartialResult and Perfdata for a single network interface.
+func processNetworkInterface(nic *networkInterface) result.PartialResult {
+	// calculate percentage of dropped packets for check result decision.
+	dropped := nic.rxDropped + nic.txDropped
+	dropPercent := 0.0
+
+	if total := nic.rxPackets + nic.txPackets + dropped; total != 0 {
+		dropPercent = dropped * 100 / total
+	}
+
+	state := internal.EvaluateThresholds(dropPercent, networkDropWarnThreshold, networkDropCritThreshold)
+	output := fmt.Sprintf("NIC %s: %.0f/%.0fKB/s rx/tx, %.2f%% packets dropped", nic.name, nic.rxKBps, nic.txKBps, dropPercent)
+
+	// calculate utilization of the busier direction relative to the link speed, if known.
+	utilization := 0.0
+
+	if nic.linkSpeedMb > 0 {
+		utilization = max(nic.rxKBps, nic.txKBps) * 1024 * 8 * 100 / float64(nic.linkSpeedMb*1000*1000)
+
+		state = result.WorstState(state, internal.EvaluateThresholds(utilization, networkWarnThreshold, networkCritThreshold))
+		output += fmt.Sprintf(", %.1f%% of %dMbit/s utilized", utilization, nic.linkSpeedMb)
+	}
+
+	pr := internal.StatePartialResult(output, state)
+
+	if nic.linkSpeedMb > 0 {
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: nic.name + "_utilization",
+			Value: utilization,
+			Uom:   "%",
+			Warn:  networkWarnThreshold,
+			Crit:  networkCritThreshold,
+			Min:   0,
+			Max:   100,
+		})
+	}
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: nic.name + "_rx_bytes_per_second",
+		Value: nic.rxKBps * 1024,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: nic.name + "_tx_bytes_per_second",
+		Value: nic.txKBps * 1024,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: nic.name + "_rx_packets",
+		Value: nic.rxPackets,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: nic.name + "_tx_packets",
+		Value: nic.txPackets,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: nic.name + "_dropped_packets_percent",
+		Value: dropPercent,
+		Uom:   "%",
+		Warn:  networkDropWarnThreshold,
+		Crit:  networkDropCritThreshold,
+	})
+
+	return pr
+}
@@ -1,9 +1,13 @@
 package cmd
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
 	"github.com/spf13/cobra"
 )
 
@@ -11,11 +15,21 @@ var nicWarning string
 var nicCritical string
 var nicWarnThreshold *check.Threshold
 var nicCritThreshold *check.Threshold
+var nicMinSpeed int64
+var nicDownState string
+var nicHalfDuplexState string
+var nicUplinks []string
 
 // nicCmd represents the nic command.
 var nicCmd = &cobra.Command{
 	Use:   "nic",
 	Short: "Checks attached NICs",
+	Long: `Checks link state, negotiated speed and duplex of each physical NIC of the ESXi host
+given by --machine, as well as the number of NICs with link up.
+
+NICs listed in --uplinks are required to be present and up, a missing or down uplink
+results in CRITICAL. Other NICs being down result in --down-state, which is OK by default
+to not alert on unused NICs.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryNic()
 	},
@@ -24,16 +38,26 @@ var nicCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(nicCmd)
 
-	nicCmd.Flags().StringVarP(&nicWarning, "warning", "w", "2", "Warning threshold as Integer (\"less than X available\")")
-	nicCmd.Flags().StringVarP(&nicCritical, "critical", "c", "1", "Critical threshold as Integer (\"less than X available\")")
+	nicCmd.Flags().StringVarP(&nicWarning, "warning", "w", "2", "Warning threshold as Integer (\"less than X up\")")
+	nicCmd.Flags().StringVarP(&nicCritical, "critical", "c", "1", "Critical threshold as Integer (\"less than X up\")")
+	nicCmd.Flags().Int64Var(&nicMinSpeed, "min-speed", 0, "Minimum negotiated speed in Mbit/s, WARNING if a NIC with link up is below")
+	nicCmd.Flags().StringVar(&nicDownState, "down-state", "ok", "State if a NIC not listed in --uplinks is down")
+	nicCmd.Flags().StringVar(&nicHalfDuplexState, "half-duplex-state", "warning", "State if a NIC with link up is in half duplex mode")
+	nicCmd.Flags().StringSliceVar(&nicUplinks, "uplinks", nil, "Comma separated list of NICs required to be up, e.g. vmnic0,vmnic1")
 }
 
 func queryNic() {
 	var (
-		err             error
-		hardwareNumNICs int16
+		err         error
+		device      string
+		linkSpeedMb int64
+		linkDuplex  string
+		nicsUp      int
+		devices     []string
 	)
 
+	aggregatedResult := result.Overall{}
+
 	// Parse thresholds from given flags.
 	nicWarnThreshold, err = check.ParseThreshold(nicWarning + ":") // `:` is needed because warning/critical are reversed.
 	if err != nil {
@@ -47,35 +71,101 @@ func queryNic() {
 
 	dbConnection := internal.DBConnection(host, port, username, password, database)
 
-	err = dbConnection.QueryRow(`SELECT hardware_num_nic 
-            FROM host_system 
-            WHERE host_system.host_name LIKE ?`,
-		machine).Scan(&hardwareNumNICs)
+	rows, err := dbConnection.Query(`SELECT pn.device,
+		COALESCE(pn.link_speed_mb, 0),
+		COALESCE(pn.link_duplex, 'n')
+		FROM host_physical_nic pn
+		INNER JOIN host_system hs
+		ON pn.host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		ORDER BY pn.device`,
+		machine)
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	pl.Add(&perfdata.Perfdata{
+	defer rows.Close()
+
+	// Process query results, a link speed of 0 means the link is down.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&device, &linkSpeedMb, &linkDuplex)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		if linkSpeedMb > 0 {
+			nicsUp++
+		}
+
+		devices = append(devices, device)
+
+		aggregatedResult.AddSubcheck(processNic(device, linkSpeedMb, linkDuplex))
+	}
+
+	dbConnection.Close()
+
+	// Required uplinks.
+	for _, uplink := range nicUplinks {
+		if !slices.Contains(devices, uplink) {
+			aggregatedResult.AddSubcheck(internal.StatePartialResult(
+				fmt.Sprintf("Required uplink %s is missing", uplink),
+				check.Critical))
+		}
+	}
+
+	// Number of NICs with link up.
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Number of NICs up: %d", nicsUp),
+		internal.EvaluateThresholds(float64(nicsUp), nicWarnThreshold, nicCritThreshold))
+	pr.Perfdata.Add(&perfdata.Perfdata{
 		Label: "nics",
-		Value: hardwareNumNICs,
+		Value: nicsUp,
 		Warn:  nicWarnThreshold,
 		Crit:  nicCritThreshold,
 	})
+	aggregatedResult.AddSubcheck(pr)
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
 
-	// Decide on check result state.
-	statusCode := check.OK
+// Computes the PartialResult and Perfdata for a single physical NIC.
+func processNic(device string, linkSpeedMb int64, linkDuplex string) result.PartialResult {
+	if linkSpeedMb == 0 {
+		output := fmt.Sprintf("NIC %s is down", device)
+		state := internal.ParseState(nicDownState)
 
-	if nicWarnThreshold.DoesViolate(float64(hardwareNumNICs)) {
-		statusCode = check.Warning
+		if slices.Contains(nicUplinks, device) {
+			output = fmt.Sprintf("Required uplink %s is down", device)
+			state = check.Critical
+		}
+
+		pr := internal.StatePartialResult(output, state)
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: device + "_speed",
+			Value: 0,
+		})
+
+		return pr
 	}
 
-	if nicCritThreshold.DoesViolate(float64(hardwareNumNICs)) {
-		statusCode = check.Critical
+	duplex := "full duplex"
+	state := check.OK
+
+	if linkDuplex != "y" {
+		duplex = "half duplex"
+		state = internal.ParseState(nicHalfDuplexState)
 	}
 
-	dbConnection.Close()
-	check.Exitf(statusCode,
-		"Number of NICs: %d | %s",
-		hardwareNumNICs,
-		pl.String())
+	if linkSpeedMb < nicMinSpeed {
+		state = result.WorstState(state, check.Warning)
+	}
+
+	pr := internal.StatePartialResult(fmt.Sprintf("NIC %s is up with %dMbit/s %s", device, linkSpeedMb, duplex), state)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: device + "_speed",
+		Value: linkSpeedMb * 1000 * 1000, // Report in Bits per second.
+	})
+
+	return pr
 }
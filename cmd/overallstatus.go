@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/spf13/cobra"
+)
+
+var overallStatusType string
+
+// Maps the --type flag values to vSphere's managed object types.
+var overallStatusObjectTypes = map[string]string{
+	"host":      "HostSystem",
+	"vm":        "VirtualMachine",
+	"datastore": "Datastore",
+	"cluster":   "ClusterComputeResource",
+}
+
+// overallStatusCmd represents the overall-status command.
+var overallStatusCmd = &cobra.Command{
+	Use:   "overall-status",
+	Short: "Checks the vSphere overall status of a host, VM, datastore or cluster",
+	Long: `Checks the overall status vSphere computes for the managed object given by --machine,
+mapping gray/green/yellow/red to UNKNOWN/OK/WARNING/CRITICAL.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryOverallStatus()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(overallStatusCmd)
+
+	overallStatusCmd.Flags().StringVarP(&overallStatusType, "type", "t", "host", "Type of the object to check (host, vm, datastore, cluster)")
+}
+
+// Query for the overall status of the given object, exit with UNKNOWN on query errors.
+func queryOverallStatus() {
+	var (
+		err           error
+		overallStatus string
+	)
+
+	objectType, ok := overallStatusObjectTypes[overallStatusType]
+	if !ok {
+		check.ExitError(fmt.Errorf("invalid object type: %s", overallStatusType))
+	}
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err = dbConnection.QueryRow(`SELECT o.overall_status
+		FROM object o
+		WHERE o.object_name LIKE ?
+		AND o.object_type = ?`,
+		machine,
+		objectType).Scan(&overallStatus)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+	check.Exitf(internal.StateFromColor(overallStatus),
+		"Overall status of %s %s is %s",
+		overallStatusType,
+		machine,
+		overallStatus)
+}
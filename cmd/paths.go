@@ -0,0 +1,135 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var pathsMinPaths int
+
+// lunPaths holds the number of storage paths per state of a single LUN.
+type lunPaths struct {
+	active  int
+	standby int
+	dead    int
+}
+
+// pathsCmd represents the paths command.
+var pathsCmd = &cobra.Command{
+	Use:   "paths",
+	Short: "Checks storage path redundancy of each LUN",
+	Long: `Checks the number of active, standby and dead storage paths of each LUN of the ESXi host
+given by --machine.
+
+A LUN with fewer than --min-paths active paths results in WARNING, a LUN without any
+active path results in CRITICAL.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryPaths()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pathsCmd)
+
+	pathsCmd.Flags().IntVar(&pathsMinPaths, "min-paths", 2, "Minimum number of active paths per LUN")
+}
+
+// Query for storage paths of the given machine, exit with UNKNOWN on query errors.
+func queryPaths() {
+	var (
+		err       error
+		lun       string
+		pathState string
+		luns      []string
+		paths     = map[string]*lunPaths{}
+	)
+
+	aggregatedResult := result.Overall{}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT sp.lun_display_name, sp.path_state
+		FROM host_storage_path sp
+		INNER JOIN host_system hs
+		ON sp.host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		ORDER BY sp.lun_display_name`,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&lun, &pathState)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		if _, ok := paths[lun]; !ok {
+			paths[lun] = &lunPaths{}
+			luns = append(luns, lun)
+		}
+
+		switch pathState {
+		case "active":
+			paths[lun].active++
+		case "standby":
+			paths[lun].standby++
+		default:
+			paths[lun].dead++
+		}
+	}
+
+	dbConnection.Close()
+
+	if len(luns) == 0 {
+		check.ExitRaw(check.Unknown, "No storage paths found for host "+machine)
+	}
+
+	// Process query results.
+	for _, name := range luns {
+		aggregatedResult.AddSubcheck(processLunPaths(name, paths[name]))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult and Perfdata for the paths of a single LUN.
+func processLunPaths(name string, paths *lunPaths) result.PartialResult {
+	state := check.OK
+
+	if paths.active < pathsMinPaths {
+		state = check.Warning
+	}
+
+	if paths.active == 0 {
+		state = check.Critical
+	}
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("LUN %s has %d active, %d standby and %d dead paths",
+			name,
+			paths.active,
+			paths.standby,
+			paths.dead),
+		state)
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: name + "_active",
+		Value: paths.active,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: name + "_dead",
+		Value: paths.dead,
+	})
+
+	return pr
+}
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var sensorsWarning []string
+var sensorsCritical []string
+
+// sensorsCmd represents the sensors command.
+var sensorsCmd = &cobra.Command{
+	Use:   "sensors",
+	Short: "Checks health state and readings of all hardware sensors",
+	Long: `Checks the health state of every hardware sensor of the ESXi host given by --machine,
+mapping green/yellow/red/unknown to OK/WARNING/CRITICAL/UNKNOWN.
+
+Numeric thresholds can be given per sensor type, e.g. --warning temperature=50 --critical temperature=60.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		querySensors()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sensorsCmd)
+
+	sensorsCmd.Flags().StringArrayVarP(&sensorsWarning, "warning", "w", nil, "Warning threshold per sensor type as type=threshold, can be repeated")
+	sensorsCmd.Flags().StringArrayVarP(&sensorsCritical, "critical", "c", nil, "Critical threshold per sensor type as type=threshold, can be repeated")
+}
+
+// Query for all hardware sensors of the given machine, exit with UNKNOWN on query errors.
+func querySensors() {
+	var (
+		err            error
+		name           string
+		sensorType     string
+		healthState    string
+		currentReading int64
+		unitModifier   int64
+		baseUnits      string
+	)
+
+	aggregatedResult := result.Overall{}
+	seen := map[string]int{}
+
+	// Parse thresholds from given flags.
+	warnThresholds := parseSensorThresholds(sensorsWarning)
+	critThresholds := parseSensorThresholds(sensorsCritical)
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT se.name,
+		se.sensor_type,
+		se.health_state,
+		se.current_reading,
+		se.unit_modifier,
+		se.base_units
+		FROM host_sensor se
+		INNER JOIN host_system hs
+		ON se.host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		ORDER BY se.sensor_type, se.name`,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&name, &sensorType, &healthState, &currentReading, &unitModifier, &baseUnits)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		value := sensorReading(currentReading, unitModifier)
+		warnThreshold := warnThresholds[strings.ToLower(sensorType)]
+		critThreshold := critThresholds[strings.ToLower(sensorType)]
+
+		state := internal.StateFromColor(healthState)
+
+		if warnThreshold != nil && warnThreshold.DoesViolate(value) {
+			state = result.WorstState(state, check.Warning)
+		}
+
+		if critThreshold != nil && critThreshold.DoesViolate(value) {
+			state = check.Critical
+		}
+
+		pr := internal.StatePartialResult(
+			fmt.Sprintf("Sensor %s (%s) is %s, reading %s %s", name, sensorType, healthState, check.FormatFloat(value), baseUnits),
+			state)
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: sensorLabel(seen, name),
+			Value: value,
+			Uom:   sensorUom(baseUnits),
+			Warn:  warnThreshold,
+			Crit:  critThreshold,
+		})
+		aggregatedResult.AddSubcheck(pr)
+	}
+
+	dbConnection.Close()
+
+	if len(aggregatedResult.PartialResults) == 0 {
+		check.ExitRaw(check.Unknown, "No sensors found for host "+machine)
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Returns the Perfdata label of a sensor, sensors sharing a name get a numbered suffix.
+func sensorLabel(seen map[string]int, name string) string {
+	seen[name]++
+	if seen[name] > 1 {
+		return fmt.Sprintf("%s_%d", name, seen[name])
+	}
+
+	return name
+}
+
+// Parses thresholds given as type=threshold into a map by sensor type, exit with UNKNOWN on parsing errors.
+func parseSensorThresholds(specs []string) map[string]*check.Threshold {
+	thresholds := make(map[string]*check.Threshold, len(specs))
+
+	for _, spec := range specs {
+		sensorType, threshold, found := strings.Cut(spec, "=")
+		if !found {
+			check.ExitError(fmt.Errorf("could not parse sensor threshold: %s", spec))
+		}
+
+		parsed, err := check.ParseThreshold(threshold)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		thresholds[strings.ToLower(sensorType)] = parsed
+	}
+
+	return thresholds
+}
+
+// Scales a raw sensor reading by its stored unit modifier (power of ten).
+func sensorReading(currentReading, unitModifier int64) float64 {
+	return float64(currentReading) * math.Pow10(int(unitModifier))
+}
+
+// Maps a sensor's base units to a perfdata unit of measurement.
+func sensorUom(baseUnits string) string {
+	switch strings.ToLower(baseUnits) {
+	case "degrees c":
+		return "C"
+	case "percent":
+		return "%"
+	}
+
+	return ""
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var snapshotsAgeWarning string
+var snapshotsAgeCritical string
+var snapshotsCountWarning string
+var snapshotsCountCritical string
+var snapshotsDepthWarning string
+var snapshotsDepthCritical string
+var snapshotsAgeWarnThreshold *check.Threshold
+var snapshotsAgeCritThreshold *check.Threshold
+var snapshotsCountWarnThreshold *check.Threshold
+var snapshotsCountCritThreshold *check.Threshold
+var snapshotsDepthWarnThreshold *check.Threshold
+var snapshotsDepthCritThreshold *check.Threshold
+var snapshotsVM string
+
+// vmSnapshot represents a single row of the vm_snapshot table.
+type vmSnapshot struct {
+	uuid       string
+	parentUUID string
+	createdAt  time.Time
+}
+
+// snapshotsCmd represents the snapshots command.
+var snapshotsCmd = &cobra.Command{
+	Use:   "snapshots",
+	Short: "Checks age, count and chain depth of VM snapshots",
+	Long: `Checks age, count and chain depth of the snapshots of all virtual machines of the vCenter
+given by --machine, or of a singular virtual machine given by --vm.
+
+Ages can be given in seconds or with one of the units s, m, h, d or w, e.g. --warning 3d.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		querySnapshots()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotsCmd)
+
+	snapshotsCmd.Flags().StringVarP(&snapshotsAgeWarning, "warning", "w", "3d", "Warning threshold for the age of the oldest snapshot")
+	snapshotsCmd.Flags().StringVarP(&snapshotsAgeCritical, "critical", "c", "7d", "Critical threshold for the age of the oldest snapshot")
+	snapshotsCmd.Flags().StringVar(&snapshotsCountWarning, "count-warning", "2", "Warning threshold for the number of snapshots per VM as Integer")
+	snapshotsCmd.Flags().StringVar(&snapshotsCountCritical, "count-critical", "5", "Critical threshold for the number of snapshots per VM as Integer")
+	snapshotsCmd.Flags().StringVar(&snapshotsDepthWarning, "depth-warning", "2", "Warning threshold for the snapshot chain depth per VM as Integer")
+	snapshotsCmd.Flags().StringVar(&snapshotsDepthCritical, "depth-critical", "4", "Critical threshold for the snapshot chain depth per VM as Integer")
+	snapshotsCmd.Flags().StringVar(&snapshotsVM, "vm", "", "Virtual machine to check, all VMs of the vCenter if empty")
+}
+
+// Query for snapshots of the given vCenter's VMs, exit with UNKNOWN on query errors.
+func querySnapshots() {
+	aggregatedResult := result.Overall{}
+
+	// Parse thresholds from given flags.
+	snapshotsAgeWarnThreshold = internal.ParseAgeThreshold(snapshotsAgeWarning)
+	snapshotsAgeCritThreshold = internal.ParseAgeThreshold(snapshotsAgeCritical)
+	snapshotsCountWarnThreshold, snapshotsCountCritThreshold = internal.ParseThresholds(snapshotsCountWarning, snapshotsCountCritical)
+	snapshotsDepthWarnThreshold, snapshotsDepthCritThreshold = internal.ParseThresholds(snapshotsDepthWarning, snapshotsDepthCritical)
+
+	vmFilter := snapshotsVM
+	if vmFilter == "" {
+		vmFilter = "%"
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	if snapshotsVM != "" && !snapshotsVMExists(dbConnection) {
+		check.ExitRaw(check.Unknown, "No VM "+snapshotsVM+" found for vCenter "+machine)
+	}
+
+	rows, err := dbConnection.Query(`SELECT o.object_name, s.uuid, s.parent_uuid, s.ts_create
+		FROM vm_snapshot s
+		INNER JOIN vcenter vc
+		ON s.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON s.vm_uuid = o.uuid
+		WHERE vc.name LIKE ?
+		AND o.object_name LIKE ?`,
+		machine,
+		vmFilter)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	vmSnapshots := collectSnapshots(rows)
+
+	dbConnection.Close()
+
+	// Sort VM names for a stable output.
+	vmNames := make([]string, 0, len(vmSnapshots))
+	for name := range vmSnapshots {
+		vmNames = append(vmNames, name)
+	}
+
+	sort.Strings(vmNames)
+
+	// Only VMs violating any of the thresholds are listed.
+	for _, name := range vmNames {
+		pr := processSnapshots(name, vmSnapshots[name])
+		if pr.GetStatus() != check.OK {
+			aggregatedResult.AddSubcheck(pr)
+		}
+	}
+
+	if len(aggregatedResult.PartialResults) == 0 {
+		aggregatedResult.AddSubcheck(internal.StatePartialResult(
+			fmt.Sprintf("No snapshots exceeding thresholds on %d VMs with snapshots", len(vmNames)),
+			check.OK))
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Reports whether the VM given by --vm exists in the vCenter, exit with UNKNOWN on query errors.
+func snapshotsVMExists(dbConnection *sql.DB) bool {
+	var count int
+
+	err := dbConnection.QueryRow(`SELECT COUNT(*)
+		FROM virtual_machine vm
+		INNER JOIN vcenter vc
+		ON vm.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		WHERE vc.name LIKE ?
+		AND o.object_name LIKE ?`,
+		machine,
+		snapshotsVM).Scan(&count)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	return count > 0
+}
+
+// Reads the snapshots per VM from the query results, exit with UNKNOWN on scan errors.
+func collectSnapshots(rows *sql.Rows) map[string][]vmSnapshot {
+	var (
+		vmName      string
+		uuid        []byte
+		parentUUID  []byte
+		tsCreate    int64
+		vmSnapshots = map[string][]vmSnapshot{}
+	)
+
+	for rows.Next() {
+		// Read row into variables.
+		err := rows.Scan(&vmName, &uuid, &parentUUID, &tsCreate)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		vmSnapshots[vmName] = append(vmSnapshots[vmName], vmSnapshot{
+			uuid:       string(uuid),
+			parentUUID: string(parentUUID),
+			createdAt:  time.UnixMilli(tsCreate),
+		})
+	}
+
+	return vmSnapshots
+}
+
+// Computes the PartialResult and Perfdata for the snapshots of a single VM.
+func processSnapshots(vmName string, snapshots []vmSnapshot) result.PartialResult {
+	parents := make(map[string]string, len(snapshots))
+	oldest := time.Now()
+
+	for _, snapshot := range snapshots {
+		parents[snapshot.uuid] = snapshot.parentUUID
+
+		if snapshot.createdAt.Before(oldest) {
+			oldest = snapshot.createdAt
+		}
+	}
+
+	// The chain depth is the longest path from any snapshot to its root snapshot.
+	maxDepth := 0
+
+	for _, snapshot := range snapshots {
+		depth := 1
+		for parent := snapshot.parentUUID; parent != "" && depth <= len(snapshots); parent = parents[parent] {
+			depth++
+		}
+
+		maxDepth = max(maxDepth, depth)
+	}
+
+	age := int64(time.Since(oldest).Seconds())
+	count := len(snapshots)
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("VM %s has %d snapshots (chain depth %d), oldest created %s",
+			vmName,
+			count,
+			maxDepth,
+			oldest.Format(time.DateTime)),
+		result.WorstState(
+			internal.EvaluateThresholds(float64(age), snapshotsAgeWarnThreshold, snapshotsAgeCritThreshold),
+			internal.EvaluateThresholds(float64(count), snapshotsCountWarnThreshold, snapshotsCountCritThreshold),
+			internal.EvaluateThresholds(float64(maxDepth), snapshotsDepthWarnThreshold, snapshotsDepthCritThreshold),
+		))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: vmName + "_snapshot_age",
+		Value: age,
+		Uom:   "s",
+		Warn:  snapshotsAgeWarnThreshold,
+		Crit:  snapshotsAgeCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: vmName + "_snapshots",
+		Value: count,
+		Warn:  snapshotsCountWarnThreshold,
+		Crit:  snapshotsCountCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: vmName + "_snapshot_depth",
+		Value: maxDepth,
+		Warn:  snapshotsDepthWarnThreshold,
+		Crit:  snapshotsDepthCritThreshold,
+	})
+
+	return pr
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/NETWAYS/check_vspheredb_data/internal"
 	"github.com/NETWAYS/go-check"
 	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
 	"github.com/spf13/cobra"
 )
 
@@ -11,11 +14,33 @@ var temperatureWarning string
 var temperatureCritical string
 var temperatureWarnThreshold *check.Threshold
 var temperatureCritThreshold *check.Threshold
+var temperatureSensor string
+var temperatureSensorType string
+var temperatureAggregate string
+
+// Sensor name used if neither --sensor nor --sensor-type are given.
+const defaultTemperatureSensor = "System Board 1 Inlet Temp"
+
+// Sensor type used if --sensor is given without --sensor-type.
+const defaultTemperatureSensorType = "temperature"
+
+// temperatureReading holds the reading of a single sensor.
+type temperatureReading struct {
+	name  string
+	value float64
+}
 
 // temperatureCmd represents the temperature command.
 var temperatureCmd = &cobra.Command{
 	Use:   "temperature",
 	Short: "Checks temperature",
+	Long: `Checks the temperature sensors of the ESXi host given by --machine.
+
+Sensors are selected by name (--sensor, an SQL LIKE pattern) and/or by type (--sensor-type).
+If neither is given, the sensor "System Board 1 Inlet Temp" is used. If only --sensor is
+given, the sensor type defaults to "temperature". Readings of multiple
+sensors are aggregated by --aggregate: "max" and "avg" report a single value, "all" reports
+every sensor on its own.`,
 	Run: func(_ *cobra.Command, _ []string) {
 		queryTemperature()
 	},
@@ -26,60 +51,143 @@ func init() {
 
 	temperatureCmd.Flags().StringVarP(&temperatureWarning, "warning", "w", "50", "Warning threshold as Integer")
 	temperatureCmd.Flags().StringVarP(&temperatureCritical, "critical", "c", "60", "Critical threshold as Integer")
+	temperatureCmd.Flags().StringVar(&temperatureSensor, "sensor", "", "Sensor name to check as SQL LIKE pattern")
+	temperatureCmd.Flags().StringVar(&temperatureSensorType, "sensor-type", "", "Sensor type to check, defaults to temperature if --sensor is given")
+	temperatureCmd.Flags().StringVar(&temperatureAggregate, "aggregate", "max", "Aggregation of multiple sensors (max, avg, all)")
 }
 
 func queryTemperature() {
 	var (
 		err            error
+		name           string
 		currentReading int64
+		unitModifier   int64
+		readings       []temperatureReading
 	)
 
+	if temperatureAggregate != "max" && temperatureAggregate != "avg" && temperatureAggregate != "all" {
+		check.ExitError(fmt.Errorf("invalid aggregation: %s", temperatureAggregate))
+	}
+
 	// Parse thresholds from given flags.
-	temperatureWarnThreshold, err = check.ParseThreshold(temperatureWarning)
+	temperatureWarnThreshold, temperatureCritThreshold = internal.ParseThresholds(temperatureWarning, temperatureCritical)
+
+	sensorFilter, sensorTypeFilter := temperatureSensorFilters()
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT se.name, se.current_reading, se.unit_modifier
+		FROM host_sensor se
+		INNER JOIN host_system hs
+		ON se.host_uuid = hs.uuid
+		WHERE hs.host_name LIKE ?
+		AND se.name LIKE ?
+		AND se.sensor_type LIKE ?
+		ORDER BY se.name`,
+		machine,
+		sensorFilter,
+		sensorTypeFilter)
 	if err != nil {
 		check.ExitError(err)
 	}
 
-	temperatureCritThreshold, err = check.ParseThreshold(temperatureCritical)
-	if err != nil {
-		check.ExitError(err)
+	defer rows.Close()
+
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&name, &currentReading, &unitModifier)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		readings = append(readings, temperatureReading{name: name, value: sensorReading(currentReading, unitModifier)})
 	}
 
-	dbConnection := internal.DBConnection(host, port, username, password, database)
+	dbConnection.Close()
 
-	err = dbConnection.QueryRow(`SELECT se.current_reading 
-        FROM host_sensor se 
-        INNER JOIN host_system hs 
-        ON se.host_uuid = hs.uuid 
-        WHERE hs.host_name LIKE ?
-		AND se.name LIKE "System Board 1 Inlet Temp"`,
-		machine).Scan(&currentReading)
-	if err != nil {
-		check.ExitError(err)
+	if len(readings) == 0 {
+		check.ExitRaw(check.Unknown, "No temperature sensors found for host "+machine)
 	}
 
+	if temperatureAggregate == "all" {
+		processTemperatures(readings)
+	}
+
+	temperature := aggregateTemperatures(readings)
+
 	pl.Add(&perfdata.Perfdata{
 		Label: "temp",
-		Value: currentReading,
+		Value: temperature,
 		Uom:   "C",
 		Warn:  temperatureWarnThreshold,
 		Crit:  temperatureCritThreshold,
 	})
 
-	// Decide on check result state.
-	statusCode := check.OK
+	check.Exitf(internal.EvaluateThresholds(temperature, temperatureWarnThreshold, temperatureCritThreshold),
+		"Temperature is %s°C (%s of %d sensors) | %s",
+		check.FormatFloat(temperature),
+		temperatureAggregate,
+		len(readings),
+		pl.String())
+}
 
-	if temperatureWarnThreshold.DoesViolate(float64(currentReading)) {
-		statusCode = check.Warning
+// Returns the SQL LIKE patterns on sensor name and type given by --sensor and --sensor-type,
+// falling back to the default sensor, or to temperature sensors if only --sensor is given.
+func temperatureSensorFilters() (string, string) {
+	sensorFilter := temperatureSensor
+	if sensorFilter == "" && temperatureSensorType == "" {
+		sensorFilter = defaultTemperatureSensor
+	} else if sensorFilter == "" {
+		sensorFilter = "%"
 	}
 
-	if temperatureCritThreshold.DoesViolate(float64(currentReading)) {
-		statusCode = check.Critical
+	sensorTypeFilter := temperatureSensorType
+	if sensorTypeFilter == "" && temperatureSensor != "" {
+		sensorTypeFilter = defaultTemperatureSensorType
+	} else if sensorTypeFilter == "" {
+		sensorTypeFilter = "%"
 	}
 
-	dbConnection.Close()
-	check.Exitf(statusCode,
-		"Temperature is %d°C | %s",
-		currentReading,
-		pl.String())
+	return sensorFilter, sensorTypeFilter
+}
+
+// Aggregates the readings into a single value by --aggregate.
+func aggregateTemperatures(readings []temperatureReading) float64 {
+	temperature := readings[0].value
+
+	for _, reading := range readings[1:] {
+		if temperatureAggregate == "avg" {
+			temperature += reading.value
+		} else {
+			temperature = max(temperature, reading.value)
+		}
+	}
+
+	if temperatureAggregate == "avg" {
+		temperature /= float64(len(readings))
+	}
+
+	return temperature
+}
+
+// Computes one PartialResult and Perfdata per sensor and exits.
+func processTemperatures(readings []temperatureReading) {
+	aggregatedResult := result.Overall{}
+	seen := map[string]int{}
+
+	for _, reading := range readings {
+		pr := internal.StatePartialResult(
+			fmt.Sprintf("Temperature of %s is %s°C", reading.name, check.FormatFloat(reading.value)),
+			internal.EvaluateThresholds(reading.value, temperatureWarnThreshold, temperatureCritThreshold))
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: sensorLabel(seen, reading.name),
+			Value: reading.value,
+			Uom:   "C",
+			Warn:  temperatureWarnThreshold,
+			Crit:  temperatureCritThreshold,
+		})
+		aggregatedResult.AddSubcheck(pr)
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
 }
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/spf13/cobra"
+)
+
+var throughputWarning string
+var throughputCritical string
+var throughputCounter string
+var throughputWindow string
+var throughputAggregate string
+var throughputWarnThreshold *check.Threshold
+var throughputCritThreshold *check.Threshold
+
+// Maps the --counter flag values to vSphere's performance counter groups.
+// Network throughput is checked per NIC by the network command.
+var throughputCounterGroups = map[string]string{
+	"disk": "disk",
+}
+
+// throughputCmd represents the throughput command.
+var throughputCmd = &cobra.Command{
+	Use:   "throughput",
+	Short: "Checks disk throughput",
+	Long: `Checks the disk throughput of the ESXi host given by --machine.
+
+The 5-minute rollups of the usage counter within --window (5m to 25m) are aggregated
+by --aggregate (avg or max).`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryThroughput()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(throughputCmd)
+
+	throughputCmd.Flags().StringVarP(&throughputWarning, "warning", "w", "100000", "Warning threshold in KB/s as Integer")
+	throughputCmd.Flags().StringVarP(&throughputCritical, "critical", "c", "1000000", "Critical threshold in KB/s as Integer")
+	throughputCmd.Flags().StringVar(&throughputCounter, "counter", "disk", "Counter to check (disk), network throughput is checked by the network command")
+	throughputCmd.Flags().StringVar(&throughputWindow, "window", "15m", "Window of performance counter rollups to evaluate")
+	throughputCmd.Flags().StringVar(&throughputAggregate, "aggregate", "avg", "Aggregation of performance counter rollups (avg, max)")
+}
+
+// Query for disk throughput of the given machine, exit with UNKNOWN on query errors.
+func queryThroughput() {
+	group, ok := throughputCounterGroups[throughputCounter]
+	if !ok {
+		check.ExitError(fmt.Errorf("invalid counter: %s", throughputCounter))
+	}
+
+	window := parseCounterWindow(throughputWindow, throughputAggregate)
+
+	// Parse thresholds from given flags.
+	throughputWarnThreshold, throughputCritThreshold = internal.ParseThresholds(throughputWarning, throughputCritical)
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	// disk.usage is reported in KB/s.
+	usage := queryHostCounter(dbConnection, group, "usage", window, throughputAggregate)
+
+	pl.Add(&perfdata.Perfdata{
+		Label: throughputCounter + "_usage_kbps",
+		Value: usage,
+		Warn:  throughputWarnThreshold,
+		Crit:  throughputCritThreshold,
+		Min:   0,
+	})
+
+	dbConnection.Close()
+	check.Exitf(internal.EvaluateThresholds(usage, throughputWarnThreshold, throughputCritThreshold),
+		"Throughput of %s (%s over %s) is %sKB/s | %s",
+		throughputCounter,
+		throughputAggregate,
+		throughputWindow,
+		check.FormatFloat(usage),
+		pl.String())
+}
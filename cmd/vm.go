@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var vmCPUWarning string
+var vmCPUCritical string
+var vmMemoryWarning string
+var vmMemoryCritical string
+var vmCPUWarnThreshold *check.Threshold
+var vmCPUCritThreshold *check.Threshold
+var vmMemoryWarnThreshold *check.Threshold
+var vmMemoryCritThreshold *check.Threshold
+
+// vmStats holds the queried data of a single virtual machine.
+type vmStats struct {
+	powerState         string
+	heartbeatStatus    string
+	numCPU             int64
+	memorySizeMB       int64
+	hostCPUMHz         int64
+	overallCPUUsage    int64
+	guestMemoryUsageMB int64
+	hostMemoryUsageMB  int64
+}
+
+// vmCmd represents the vm command.
+var vmCmd = &cobra.Command{
+	Use:   "vm",
+	Short: "Checks CPU, memory, power state and guest heartbeat of a virtual machine",
+	Long: `Checks CPU, memory, power state and guest heartbeat of the virtual machine given by --machine.
+
+CPU usage is calculated against the number of virtual CPUs and the clock speed of the
+VM's current ESXi host, memory usage is calculated from the guest memory usage against
+the configured memory size. The power state maps poweredOn to OK, suspended to WARNING
+and poweredOff to CRITICAL, the guest heartbeat maps green/yellow/red/gray to
+OK/WARNING/CRITICAL/UNKNOWN.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryVM()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vmCmd)
+
+	vmCmd.Flags().StringVarP(&vmCPUWarning, "warning", "w", "80", "Warning threshold for CPU usage in percent as Integer")
+	vmCmd.Flags().StringVarP(&vmCPUCritical, "critical", "c", "90", "Critical threshold for CPU usage in percent as Integer")
+	vmCmd.Flags().StringVar(&vmMemoryWarning, "memory-warning", "80", "Warning threshold for guest memory usage in percent as Integer")
+	vmCmd.Flags().StringVar(&vmMemoryCritical, "memory-critical", "90", "Critical threshold for guest memory usage in percent as Integer")
+}
+
+// Query for CPU, memory, power state and heartbeat of the given virtual machine, exit with UNKNOWN on query errors.
+func queryVM() {
+	var stats vmStats
+
+	// Parse thresholds from given flags.
+	vmCPUWarnThreshold, vmCPUCritThreshold = internal.ParseThresholds(vmCPUWarning, vmCPUCritical)
+	vmMemoryWarnThreshold, vmMemoryCritThreshold = internal.ParseThresholds(vmMemoryWarning, vmMemoryCritical)
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err := dbConnection.QueryRow(`SELECT vm.runtime_power_state,
+		COALESCE(vqs.guest_heartbeat_status, 'gray'),
+		vm.hardware_numcpu,
+		vm.hardware_memorysize_mb,
+		COALESCE(hs.hardware_cpu_mhz, 0),
+		COALESCE(vqs.overall_cpu_usage, 0),
+		COALESCE(vqs.guest_memory_usage_mb, 0),
+		COALESCE(vqs.host_memory_usage_mb, 0)
+		FROM virtual_machine vm
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		LEFT JOIN vm_quick_stats vqs
+		ON vm.uuid = vqs.uuid
+		LEFT JOIN host_system hs
+		ON vm.runtime_host_uuid = hs.uuid
+		WHERE o.object_name LIKE ?`,
+		machine).Scan(&stats.powerState, &stats.heartbeatStatus, &stats.numCPU, &stats.memorySizeMB,
+		&stats.hostCPUMHz, &stats.overallCPUUsage, &stats.guestMemoryUsageMB, &stats.hostMemoryUsageMB)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+
+	aggregatedResult := result.Overall{}
+
+	aggregatedResult.AddSubcheck(vmPowerStateResult(stats.powerState))
+	aggregatedResult.AddSubcheck(vmHeartbeatResult(stats.heartbeatStatus))
+	aggregatedResult.AddSubcheck(vmCPUResult(stats))
+	aggregatedResult.AddSubcheck(vmMemoryResult(stats))
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult for the VM's power state.
+func vmPowerStateResult(powerState string) result.PartialResult {
+	state := check.Unknown
+
+	switch powerState {
+	case "poweredOn":
+		state = check.OK
+	case "suspended":
+		state = check.Warning
+	case "poweredOff":
+		state = check.Critical
+	}
+
+	return internal.StatePartialResult("Power state is "+powerState, state)
+}
+
+// Computes the PartialResult for the VM's guest heartbeat status.
+func vmHeartbeatResult(heartbeatStatus string) result.PartialResult {
+	return internal.StatePartialResult("Guest heartbeat status is "+heartbeatStatus, internal.StateFromColor(heartbeatStatus))
+}
+
+// Computes the PartialResult and Perfdata for the VM's CPU usage.
+func vmCPUResult(stats vmStats) result.PartialResult {
+	// calculate percentage usage for check result decision.
+	cpuUsagePercent := int64(0)
+	if stats.numCPU*stats.hostCPUMHz != 0 {
+		cpuUsagePercent = stats.overallCPUUsage * 100 / (stats.numCPU * stats.hostCPUMHz)
+	}
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("CPU usage is %dMHz (%d%%)", stats.overallCPUUsage, cpuUsagePercent),
+		internal.EvaluateThresholds(float64(cpuUsagePercent), vmCPUWarnThreshold, vmCPUCritThreshold))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "cpu_usage",
+		Value: stats.overallCPUUsage,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "cpu_usage_percent",
+		Value: cpuUsagePercent,
+		Uom:   "%",
+		Warn:  vmCPUWarnThreshold,
+		Crit:  vmCPUCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "cpus",
+		Value: stats.numCPU,
+	})
+
+	return pr
+}
+
+// Computes the PartialResult and Perfdata for the VM's memory usage.
+func vmMemoryResult(stats vmStats) result.PartialResult {
+	// calculate percentage usage for check result decision.
+	memoryUsagePercent := int64(0)
+	if stats.memorySizeMB != 0 {
+		memoryUsagePercent = stats.guestMemoryUsageMB * 100 / stats.memorySizeMB
+	}
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Guest memory usage is %dMB (%d%%), host memory usage is %dMB",
+			stats.guestMemoryUsageMB,
+			memoryUsagePercent,
+			stats.hostMemoryUsageMB),
+		internal.EvaluateThresholds(float64(memoryUsagePercent), vmMemoryWarnThreshold, vmMemoryCritThreshold))
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "guest_memory_usage",
+		Value: stats.guestMemoryUsageMB * 1024 * 1024, // Report in Bytes.
+		Uom:   "B",
+		Min:   0,
+		Max:   stats.memorySizeMB * 1024 * 1024,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "guest_memory_usage_percent",
+		Value: memoryUsagePercent,
+		Uom:   "%",
+		Warn:  vmMemoryWarnThreshold,
+		Crit:  vmMemoryCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: "host_memory_usage",
+		Value: stats.hostMemoryUsageMB * 1024 * 1024, // Report in Bytes.
+		Uom:   "B",
+	})
+
+	return pr
+}
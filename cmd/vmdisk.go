@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/convert"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var vmDiskWarning string
+var vmDiskCritical string
+var vmDiskFreeWarning string
+var vmDiskFreeCritical string
+var vmDiskInclude string
+var vmDiskExclude string
+var vmDiskWarnThreshold *check.Threshold
+var vmDiskCritThreshold *check.Threshold
+var vmDiskFreeWarnThreshold *check.Threshold
+var vmDiskFreeCritThreshold *check.Threshold
+
+// vmDiskCmd represents the vm-disk command.
+var vmDiskCmd = &cobra.Command{
+	Use:   "vm-disk",
+	Short: "Checks guest filesystem usage of a virtual machine",
+	Long: `Checks the usage of each guest filesystem of the virtual machine given by --machine,
+as reported by VMware Tools.
+
+Thresholds on free space are optional and can be given with a unit, e.g. --warning-free 10GB.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		queryVMDisks()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vmDiskCmd)
+
+	vmDiskCmd.Flags().StringVarP(&vmDiskWarning, "warning", "w", "80", "Warning threshold for used space in percent as Integer")
+	vmDiskCmd.Flags().StringVarP(&vmDiskCritical, "critical", "c", "90", "Critical threshold for used space in percent as Integer")
+	vmDiskCmd.Flags().StringVar(&vmDiskFreeWarning, "warning-free", "", "Warning threshold for free space in bytes (\"less than X free\")")
+	vmDiskCmd.Flags().StringVar(&vmDiskFreeCritical, "critical-free", "", "Critical threshold for free space in bytes (\"less than X free\")")
+	vmDiskCmd.Flags().StringVar(&vmDiskInclude, "include", "", "Regular expression of mount paths to include")
+	vmDiskCmd.Flags().StringVar(&vmDiskExclude, "exclude", "", "Regular expression of mount paths to exclude")
+}
+
+// Query for guest filesystem usage of the given machine, exit with UNKNOWN on query errors.
+func queryVMDisks() {
+	var (
+		err       error
+		diskPath  string
+		capacity  int64
+		freeSpace int64
+	)
+
+	aggregatedResult := result.Overall{}
+
+	// Parse thresholds from given flags.
+	vmDiskWarnThreshold, vmDiskCritThreshold = internal.ParseThresholds(vmDiskWarning, vmDiskCritical)
+
+	if vmDiskFreeWarning != "" {
+		vmDiskFreeWarnThreshold = internal.ParseFreeBytesThreshold(vmDiskFreeWarning)
+	}
+
+	if vmDiskFreeCritical != "" {
+		vmDiskFreeCritThreshold = internal.ParseFreeBytesThreshold(vmDiskFreeCritical)
+	}
+
+	include, err := regexp.Compile(vmDiskInclude)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	exclude, err := regexp.Compile(vmDiskExclude)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT du.disk_path, du.capacity, du.free_space
+		FROM vm_disk_usage du
+		INNER JOIN object o
+		ON du.vm_uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		ORDER BY du.disk_path`,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&diskPath, &capacity, &freeSpace)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		if !include.MatchString(diskPath) || (vmDiskExclude != "" && exclude.MatchString(diskPath)) {
+			continue
+		}
+
+		aggregatedResult.AddSubcheck(processVMDisk(diskPath, capacity, freeSpace))
+	}
+
+	dbConnection.Close()
+
+	if len(aggregatedResult.PartialResults) == 0 {
+		check.ExitRaw(check.Unknown, "No guest filesystems found for VM "+machine)
+	}
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Computes the PartialResult and Perfdata for a single guest filesystem.
+func processVMDisk(diskPath string, capacity, freeSpace int64) result.PartialResult {
+	// calculate percentage usage for check result decision.
+	usedPercent := int64(0)
+	if capacity != 0 {
+		usedPercent = (capacity - freeSpace) * 100 / capacity
+	}
+
+	state := internal.EvaluateThresholds(float64(usedPercent), vmDiskWarnThreshold, vmDiskCritThreshold)
+
+	if vmDiskFreeWarnThreshold != nil && vmDiskFreeWarnThreshold.DoesViolate(float64(freeSpace)) {
+		state = max(state, check.Warning)
+	}
+
+	if vmDiskFreeCritThreshold != nil && vmDiskFreeCritThreshold.DoesViolate(float64(freeSpace)) {
+		state = check.Critical
+	}
+
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("Used space for %s: %d%% (%s free)",
+			diskPath,
+			usedPercent,
+			convert.BytesIEC(freeSpace).HumanReadable()),
+		state)
+
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: diskPath + "_used",
+		Value: usedPercent,
+		Uom:   "%",
+		Warn:  vmDiskWarnThreshold,
+		Crit:  vmDiskCritThreshold,
+	})
+	pr.Perfdata.Add(&perfdata.Perfdata{
+		Label: diskPath + "_free",
+		Value: freeSpace,
+		Uom:   "B",
+		Warn:  vmDiskFreeWarnThreshold,
+		Crit:  vmDiskFreeCritThreshold,
+		Min:   0,
+		Max:   capacity,
+	})
+
+	return pr
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/NETWAYS/check_vspheredb_data/internal"
+	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/perfdata"
+	"github.com/NETWAYS/go-check/result"
+	"github.com/spf13/cobra"
+)
+
+var vmToolsNotRunningState string
+var vmToolsOutdatedState string
+var vmToolsNotInstalledState string
+var vmToolsVM string
+
+// vmToolsCmd represents the vm-tools command.
+var vmToolsCmd = &cobra.Command{
+	Use:   "vm-tools",
+	Short: "Checks VMware Tools status of all VMs or a singular, specified VM",
+	Long: `Checks whether VMware Tools are installed, running and up to date on the virtual machine
+given by --vm, or on all powered on virtual machines of the vCenter given by --machine.
+
+States can be given by name (ok, warning, critical, unknown) or by their numeric value.`,
+	Run: func(_ *cobra.Command, _ []string) {
+		if vmToolsVM == "" {
+			queryVMToolsOverview()
+		} else {
+			queryVMTools()
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(vmToolsCmd)
+
+	vmToolsCmd.Flags().StringVar(&vmToolsNotRunningState, "not-running-state", "critical", "State if VMware Tools are not running")
+	vmToolsCmd.Flags().StringVar(&vmToolsOutdatedState, "outdated-state", "warning", "State if VMware Tools are outdated")
+	vmToolsCmd.Flags().StringVar(&vmToolsNotInstalledState, "not-installed-state", "warning", "State if VMware Tools are not installed")
+	vmToolsCmd.Flags().StringVar(&vmToolsVM, "vm", "", "Virtual machine to check, all VMs of the vCenter if empty")
+}
+
+// Query for VMware Tools status of the given VM, exit with UNKNOWN on query errors.
+func queryVMTools() {
+	var (
+		err           error
+		toolsStatus   string
+		runningStatus string
+		toolsVersion  string
+	)
+
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	err = dbConnection.QueryRow(`SELECT COALESCE(vm.guest_tools_status, 'toolsNotInstalled'),
+		COALESCE(vm.guest_tools_running_status, 'guestToolsNotRunning'),
+		COALESCE(vm.guest_tools_version, '')
+		FROM virtual_machine vm
+		INNER JOIN vcenter vc
+		ON vm.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		WHERE o.object_name LIKE ?
+		AND vc.name LIKE ?`,
+		vmToolsVM,
+		machine).Scan(&toolsStatus, &runningStatus, &toolsVersion)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	dbConnection.Close()
+
+	statusCode, description := processVMToolsStatus(toolsStatus, runningStatus)
+
+	check.Exitf(statusCode,
+		"VMware Tools on VM %s are %s (version %s)",
+		vmToolsVM,
+		description,
+		toolsVersion)
+}
+
+// Query for VMware Tools status of all powered on VMs of the given vCenter, exit with UNKNOWN on query errors.
+func queryVMToolsOverview() {
+	var (
+		err           error
+		vmName        string
+		toolsStatus   string
+		runningStatus string
+		toolsVersion  string
+	)
+
+	aggregatedResult := result.Overall{}
+	counts := map[int]int{}
+
+	// Collect query results.
+	dbConnection := internal.DBConnection(host, port, username, password, database)
+
+	rows, err := dbConnection.Query(`SELECT o.object_name,
+		COALESCE(vm.guest_tools_status, 'toolsNotInstalled'),
+		COALESCE(vm.guest_tools_running_status, 'guestToolsNotRunning'),
+		COALESCE(vm.guest_tools_version, '')
+		FROM virtual_machine vm
+		INNER JOIN vcenter vc
+		ON vm.vcenter_uuid = vc.instance_uuid
+		INNER JOIN object o
+		ON vm.uuid = o.uuid
+		WHERE vc.name LIKE ?
+		AND vm.template = 'n'
+		AND vm.runtime_power_state = 'poweredOn'
+		ORDER BY o.object_name`,
+		machine)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	defer rows.Close()
+
+	// Process query results, only VMs with a non-OK state are listed.
+	for rows.Next() {
+		// Read row into variables.
+		err = rows.Scan(&vmName, &toolsStatus, &runningStatus, &toolsVersion)
+		if err != nil {
+			check.ExitError(err)
+		}
+
+		state, description := processVMToolsStatus(toolsStatus, runningStatus)
+		counts[state]++
+
+		if state == check.OK {
+			continue
+		}
+
+		aggregatedResult.AddSubcheck(internal.StatePartialResult(
+			fmt.Sprintf("VMware Tools on VM %s are %s (version %s)", vmName, description, toolsVersion),
+			state))
+	}
+
+	dbConnection.Close()
+
+	// Add a summary including perfdata on the number of VMs per state.
+	pr := internal.StatePartialResult(
+		fmt.Sprintf("VMware Tools are OK on %d VMs", counts[check.OK]),
+		check.OK)
+
+	for _, state := range []int{check.OK, check.Warning, check.Critical, check.Unknown} {
+		pr.Perfdata.Add(&perfdata.Perfdata{
+			Label: "vms_" + strings.ToLower(check.StatusText(state)),
+			Value: counts[state],
+		})
+	}
+
+	aggregatedResult.AddSubcheck(pr)
+
+	check.ExitRaw(aggregatedResult.GetStatus(), aggregatedResult.GetOutput()) // ExitRaw because of 'nested formatting issues' otherwise.
+}
+
+// Maps VMware Tools status columns to a check result state and a description.
+func processVMToolsStatus(toolsStatus, runningStatus string) (int, string) {
+	switch {
+	case toolsStatus == "toolsNotInstalled":
+		return internal.ParseState(vmToolsNotInstalledState), "not installed"
+	case toolsStatus == "toolsNotRunning" || runningStatus == "guestToolsNotRunning":
+		return internal.ParseState(vmToolsNotRunningState), "not running"
+	case toolsStatus == "toolsOld":
+		return internal.ParseState(vmToolsOutdatedState), "outdated"
+	case toolsStatus == "toolsOk":
+		return check.OK, "running and up to date"
+	}
+
+	return check.Unknown, "in unknown state " + toolsStatus
+}
@@ -4,11 +4,16 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/NETWAYS/go-check"
+	"github.com/NETWAYS/go-check/convert"
+	"github.com/NETWAYS/go-check/result"
 
 	// needed to use the MySQL driver for the sql module.
 	_ "github.com/go-sql-driver/mysql"
@@ -72,3 +77,151 @@ func DBConnection(host string, port int16, username string, password string, dat
 
 	return db
 }
+
+// ParseThresholds parses the given warning and critical threshold strings.
+//
+// If parsing fails, check exits with UNKNOWN state.
+func ParseThresholds(warning string, critical string) (*check.Threshold, *check.Threshold) {
+	warnThreshold, err := check.ParseThreshold(warning)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	critThreshold, err := check.ParseThreshold(critical)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	return warnThreshold, critThreshold
+}
+
+// EvaluateThresholds decides on a check result state for the given value.
+func EvaluateThresholds(value float64, warnThreshold *check.Threshold, critThreshold *check.Threshold) int {
+	statusCode := check.OK
+
+	if warnThreshold.DoesViolate(value) {
+		statusCode = check.Warning
+	}
+
+	if critThreshold.DoesViolate(value) {
+		statusCode = check.Critical
+	}
+
+	return statusCode
+}
+
+// StateFromColor maps vSphere's status colors (gray, green, yellow, red) to check result states.
+func StateFromColor(color string) int {
+	switch color {
+	case "green":
+		return check.OK
+	case "yellow":
+		return check.Warning
+	case "red":
+		return check.Critical
+	default:
+		return check.Unknown
+	}
+}
+
+// ParseAge parses an age like `90m`, `12h`, `3d` or `2w` into a time.Duration.
+// Plain numbers are interpreted as seconds.
+func ParseAge(age string) (time.Duration, error) {
+	units := map[string]time.Duration{
+		"s": time.Second,
+		"m": time.Minute,
+		"h": time.Hour,
+		"d": 24 * time.Hour,
+		"w": 7 * 24 * time.Hour,
+	}
+
+	if age == "" {
+		return 0, errors.New("could not parse empty age")
+	}
+
+	unit := time.Second
+
+	if multiplier, ok := units[age[len(age)-1:]]; ok {
+		unit = multiplier
+		age = age[:len(age)-1]
+	}
+
+	value, err := strconv.ParseFloat(age, 64)
+	if err != nil {
+		return 0, fmt.Errorf("could not parse age: %s", age)
+	}
+
+	return time.Duration(value * float64(unit)), nil
+}
+
+// ParseAgeThreshold parses an age given as upper bound, e.g. `3d`, into a Threshold in seconds.
+//
+// If parsing fails, check exits with UNKNOWN state.
+func ParseAgeThreshold(age string) *check.Threshold {
+	duration, err := ParseAge(age)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	threshold, err := check.ParseThreshold(strconv.FormatInt(int64(duration.Seconds()), 10))
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	return threshold
+}
+
+// ParseFreeBytesThreshold parses an amount of bytes with an optional unit, e.g. `500GB`, into a
+// Threshold alerting when less than the given amount is free.
+//
+// If parsing fails, check exits with UNKNOWN state.
+func ParseFreeBytesThreshold(bytes string) *check.Threshold {
+	value, err := convert.ParseBytes(bytes)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	threshold, err := check.ParseThreshold(strconv.FormatUint(value.Bytes(), 10) + ":") // `:` is needed because warning/critical are reversed.
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	return threshold
+}
+
+// ParseState parses a check result state given by name (ok, warning, critical, unknown)
+// or by its numeric value.
+//
+// If parsing fails, check exits with UNKNOWN state.
+func ParseState(state string) int {
+	switch strings.ToLower(state) {
+	case "0", "ok":
+		return check.OK
+	case "1", "warning":
+		return check.Warning
+	case "2", "critical":
+		return check.Critical
+	case "3", "unknown":
+		return check.Unknown
+	}
+
+	check.ExitError(fmt.Errorf("could not parse state: %s", state))
+
+	return check.Unknown
+}
+
+// StatePartialResult creates a PartialResult with the given output and state.
+//
+// If the state is invalid, check exits with UNKNOWN state.
+func StatePartialResult(output string, state int) result.PartialResult {
+	pr := result.PartialResult{
+		Output: output,
+	}
+
+	err := pr.SetState(state)
+	if err != nil {
+		check.ExitError(err)
+	}
+
+	return pr
+}